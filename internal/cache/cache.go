@@ -0,0 +1,165 @@
+// Package cache 提供分层的搜索结果缓存：有容量上限的内存LRU层和可选的磁盘文件层
+package cache
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// cleanupInterval 过期条目清理间隔
+const cleanupInterval = 5 * time.Minute
+
+// Entry 缓存条目
+// ExpiresAt 之前为新鲜数据，ExpiresAt 到 StaleUntil 之间为过期但仍可返回的旧数据
+type Entry struct {
+	Value      []byte
+	ExpiresAt  time.Time
+	StaleUntil time.Time
+}
+
+// Expired 判断条目是否已过期（不再新鲜）
+func (e *Entry) Expired(now time.Time) bool {
+	return now.After(e.ExpiresAt)
+}
+
+// Dead 判断条目是否已超过可返回旧数据的期限
+func (e *Entry) Dead(now time.Time) bool {
+	return now.After(e.StaleUntil)
+}
+
+// size 估算条目占用的字节数
+func (e *Entry) size(key string) int64 {
+	return int64(len(key) + len(e.Value) + entryOverhead)
+}
+
+// entryOverhead 每个条目的固定开销估算（map、链表节点和时间字段）
+const entryOverhead = 128
+
+// Backend 缓存存储后端
+type Backend interface {
+	// Get 获取条目，不检查是否过期
+	Get(key string) (*Entry, bool)
+	// Set 保存条目，超出容量时淘汰最久未使用的条目
+	Set(key string, e *Entry)
+	// Delete 删除条目
+	Delete(key string)
+	// Purge 删除 StaleUntil 早于 before 的条目，返回删除的数量
+	Purge(before time.Time) int
+	// Stats 返回条目数和占用字节数
+	Stats() (entries int, bytes int64)
+	// Close 释放资源
+	Close() error
+}
+
+// Cache 分层缓存：先查内存层，未命中再查文件层并回填内存层
+type Cache struct {
+	tiers     []Backend
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// New 创建分层缓存，tiers 按查询顺序排列（通常为内存层、文件层）
+func New(tiers ...Backend) *Cache {
+	c := &Cache{
+		tiers: tiers,
+		stop:  make(chan struct{}),
+	}
+	go c.cleanupLoop()
+	return c
+}
+
+// Get 获取缓存值，fresh 表示是否仍在有效期内
+// 过期但未超过旧数据期限的条目仍会返回，由调用方决定是否使用并刷新
+func (c *Cache) Get(key string) (value []byte, fresh bool, ok bool) {
+	now := time.Now()
+	for i, tier := range c.tiers {
+		e, ok := tier.Get(key)
+		if !ok {
+			continue
+		}
+		if e.Dead(now) {
+			tier.Delete(key)
+			continue
+		}
+		// 回填到更快的层
+		for _, upper := range c.tiers[:i] {
+			upper.Set(key, e)
+		}
+		return e.Value, !e.Expired(now), true
+	}
+	return nil, false, false
+}
+
+// Set 写入所有层，ttl 后过期，再过 staleTTL 后删除
+func (c *Cache) Set(key string, value []byte, ttl, staleTTL time.Duration) {
+	now := time.Now()
+	e := &Entry{
+		Value:      value,
+		ExpiresAt:  now.Add(ttl),
+		StaleUntil: now.Add(ttl + staleTTL),
+	}
+	for _, tier := range c.tiers {
+		tier.Set(key, e)
+	}
+}
+
+// Delete 从所有层删除
+func (c *Cache) Delete(key string) {
+	for _, tier := range c.tiers {
+		tier.Delete(key)
+	}
+}
+
+// Clear 清空所有层
+func (c *Cache) Clear() {
+	forever := time.Now().AddDate(100, 0, 0)
+	for _, tier := range c.tiers {
+		tier.Purge(forever)
+	}
+}
+
+// Stats 返回每一层的条目数和占用字节数
+func (c *Cache) Stats() []TierStats {
+	stats := make([]TierStats, 0, len(c.tiers))
+	for _, tier := range c.tiers {
+		entries, bytes := tier.Stats()
+		stats = append(stats, TierStats{Entries: entries, Bytes: bytes})
+	}
+	return stats
+}
+
+// TierStats 单层缓存的统计信息
+type TierStats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// cleanupLoop 定期清理过期条目
+func (c *Cache) cleanupLoop() {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case now := <-ticker.C:
+			for _, tier := range c.tiers {
+				tier.Purge(now)
+			}
+		}
+	}
+}
+
+// Close 停止清理并关闭所有层
+func (c *Cache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		for _, tier := range c.tiers {
+			if err := tier.Close(); err != nil {
+				log.Printf("[缓存] 关闭缓存失败: %v", err)
+			}
+		}
+	})
+}
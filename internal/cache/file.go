@@ -0,0 +1,366 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pansou-openwrt/internal/fsutil"
+)
+
+const (
+	fileMagic  = "PSC2"   // 缓存文件格式标识，格式变化时旧文件在加载时被删除
+	fileSuffix = ".cache" // 缓存文件扩展名
+)
+
+// File 磁盘文件缓存，每个条目一个gzip压缩的文件，重启后仍然有效
+// 文件格式：magic(4) + 过期时间(8) + 旧数据期限(8) + key长度(2) + key + gzip(value)
+type File struct {
+	mu         sync.Mutex
+	dir        string
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	ll         *list.List               // 最近使用的在前
+	items      map[string]*list.Element // key -> 链表节点
+}
+
+type fileItem struct {
+	key        string
+	name       string
+	size       int64
+	expiresAt  time.Time
+	staleUntil time.Time
+}
+
+// NewFile 创建文件缓存并加载目录中已有的条目，maxEntries 或 maxBytes 为0表示不限制该项
+func NewFile(dir string, maxEntries int, maxBytes int64) (*File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+
+	f := &File{
+		dir:        dir,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// load 扫描缓存目录，按修改时间恢复LRU顺序，删除过期和损坏的文件
+func (f *File) load() error {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return fmt.Errorf("读取缓存目录失败: %w", err)
+	}
+
+	type loaded struct {
+		item    *fileItem
+		modTime time.Time
+	}
+	items := make([]loaded, 0, len(entries))
+	now := time.Now()
+
+	for _, de := range entries {
+		name := de.Name()
+		path := filepath.Join(f.dir, name)
+		if strings.HasSuffix(name, ".tmp") {
+			os.Remove(path)
+			continue
+		}
+		if de.IsDir() || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		key, expiresAt, staleUntil, err := readHeader(path)
+		if err != nil || now.After(staleUntil) || fileName(key) != name {
+			os.Remove(path)
+			continue
+		}
+		items = append(items, loaded{
+			item: &fileItem{
+				key:        key,
+				name:       name,
+				size:       info.Size(),
+				expiresAt:  expiresAt,
+				staleUntil: staleUntil,
+			},
+			modTime: info.ModTime(),
+		})
+	}
+
+	// 最近写入的放在前面
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].modTime.After(items[j].modTime)
+	})
+	for _, l := range items {
+		f.items[l.item.key] = f.ll.PushBack(l.item)
+		f.bytes += l.item.size
+	}
+	for f.overflow() {
+		f.removeElement(f.ll.Back())
+	}
+
+	if f.ll.Len() > 0 {
+		log.Printf("[缓存] 从 %s 加载了 %d 条缓存", f.dir, f.ll.Len())
+	}
+	return nil
+}
+
+// Get 读取条目并标记为最近使用，文件损坏时删除该条目
+// 读取和解压文件不持有锁，不会阻塞并发的 Get 和 Set；过期时间取自文件头，与读到的值一致
+func (f *File) Get(key string) (*Entry, bool) {
+	f.mu.Lock()
+	el, ok := f.items[key]
+	if !ok {
+		f.mu.Unlock()
+		return nil, false
+	}
+	snapshot := *el.Value.(*fileItem)
+	f.mu.Unlock()
+
+	e, err := readEntry(filepath.Join(f.dir, snapshot.name), key)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// 读取期间条目已被删除或淘汰
+	if current, ok := f.items[key]; !ok || current != el {
+		return nil, false
+	}
+	if err != nil {
+		// 读取期间被重新写入时不删除新的条目
+		if *el.Value.(*fileItem) == snapshot {
+			log.Printf("[缓存] 读取缓存文件失败: %v", err)
+			f.removeElement(el)
+		}
+		return nil, false
+	}
+	f.ll.MoveToFront(el)
+	return e, true
+}
+
+// Set 写入条目，写入失败时只记录日志
+// 压缩和写文件不持有锁，不会阻塞并发的 Get
+func (f *File) Set(key string, e *Entry) {
+	data, err := encodeFile(key, e)
+	if err != nil {
+		log.Printf("[缓存] 编码缓存失败: %v", err)
+		return
+	}
+
+	size := int64(len(data))
+	if f.maxBytes > 0 && size > f.maxBytes {
+		f.Delete(key)
+		return
+	}
+
+	name := fileName(key)
+	if err := fsutil.WriteFileAtomic(filepath.Join(f.dir, name), data, 0644); err != nil {
+		log.Printf("[缓存] 写入缓存文件失败: %v", err)
+		f.Delete(key)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if el, ok := f.items[key]; ok {
+		item := el.Value.(*fileItem)
+		f.bytes += size - item.size
+		item.size = size
+		item.expiresAt = e.ExpiresAt
+		item.staleUntil = e.StaleUntil
+		f.ll.MoveToFront(el)
+	} else {
+		item := &fileItem{
+			key:        key,
+			name:       name,
+			size:       size,
+			expiresAt:  e.ExpiresAt,
+			staleUntil: e.StaleUntil,
+		}
+		f.items[key] = f.ll.PushFront(item)
+		f.bytes += size
+	}
+
+	for f.overflow() {
+		f.removeElement(f.ll.Back())
+	}
+}
+
+// overflow 判断是否超出容量，调用方需持有锁
+func (f *File) overflow() bool {
+	if f.ll.Len() == 0 {
+		return false
+	}
+	return (f.maxEntries > 0 && f.ll.Len() > f.maxEntries) ||
+		(f.maxBytes > 0 && f.bytes > f.maxBytes)
+}
+
+// Delete 删除条目
+func (f *File) Delete(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.remove(key)
+}
+
+func (f *File) remove(key string) {
+	if el, ok := f.items[key]; ok {
+		f.removeElement(el)
+	}
+}
+
+func (f *File) removeElement(el *list.Element) {
+	item := el.Value.(*fileItem)
+	f.ll.Remove(el)
+	delete(f.items, item.key)
+	f.bytes -= item.size
+	if err := os.Remove(filepath.Join(f.dir, item.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("[缓存] 删除缓存文件失败: %v", err)
+	}
+}
+
+// Purge 删除超过旧数据期限的条目
+func (f *File) Purge(before time.Time) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for el := f.ll.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*fileItem).staleUntil.Before(before) {
+			f.removeElement(el)
+			n++
+		}
+		el = next
+	}
+	return n
+}
+
+// Stats 返回条目数和占用字节数
+func (f *File) Stats() (int, int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ll.Len(), f.bytes
+}
+
+// Close 文件缓存无需释放资源，条目保留在磁盘上供下次启动使用
+func (f *File) Close() error {
+	return nil
+}
+
+// fileName 根据key生成文件名
+func fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16]) + fileSuffix
+}
+
+// encodeFile 编码缓存文件内容
+func encodeFile(key string, e *Entry) ([]byte, error) {
+	if len(key) > 0xFFFF {
+		return nil, fmt.Errorf("key过长: %d", len(key))
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fileMagic)
+	binary.Write(&buf, binary.BigEndian, e.ExpiresAt.UnixNano())
+	binary.Write(&buf, binary.BigEndian, e.StaleUntil.UnixNano())
+	binary.Write(&buf, binary.BigEndian, uint16(len(key)))
+	buf.WriteString(key)
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(e.Value); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeHeader 解析文件头，返回key、过期时间和旧数据期限
+func decodeHeader(r io.Reader) (key string, expiresAt, staleUntil time.Time, err error) {
+	magic := make([]byte, len(fileMagic))
+	if _, err = io.ReadFull(r, magic); err != nil {
+		return
+	}
+	if string(magic) != fileMagic {
+		err = fmt.Errorf("缓存文件格式不正确")
+		return
+	}
+
+	var times [2]int64
+	if err = binary.Read(r, binary.BigEndian, &times); err != nil {
+		return
+	}
+	var keyLen uint16
+	if err = binary.Read(r, binary.BigEndian, &keyLen); err != nil {
+		return
+	}
+	buf := make([]byte, keyLen)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		return
+	}
+	return string(buf), time.Unix(0, times[0]), time.Unix(0, times[1]), nil
+}
+
+// readHeader 只读取文件头，用于启动时重建索引
+func readHeader(path string) (string, time.Time, time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", time.Time{}, time.Time{}, err
+	}
+	defer file.Close()
+	return decodeHeader(file)
+}
+
+// readEntry 读取并解压缓存条目，文件中的key与 key 不一致时返回错误
+func readEntry(path, key string) (*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fileKey, expiresAt, staleUntil, err := decodeHeader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	if fileKey != key {
+		return nil, fmt.Errorf("%s: 缓存文件的key不匹配", filepath.Base(path))
+	}
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	defer zr.Close()
+
+	value, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return &Entry{Value: value, ExpiresAt: expiresAt, StaleUntil: staleUntil}, nil
+}
@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testEntry(value string) *Entry {
+	now := time.Now()
+	return &Entry{Value: []byte(value), ExpiresAt: now.Add(time.Minute), StaleUntil: now.Add(time.Hour)}
+}
+
+func TestFileGetSet(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewFile(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := testEntry("三体")
+	f.Set("k", want)
+	got, ok := f.Get("k")
+	if !ok || string(got.Value) != "三体" {
+		t.Fatalf("Get = %v, %v", got, ok)
+	}
+	if !got.ExpiresAt.Equal(want.ExpiresAt) || !got.StaleUntil.Equal(want.StaleUntil) {
+		t.Errorf("过期时间 = %v / %v", got.ExpiresAt, got.StaleUntil)
+	}
+
+	// 重新打开后仍然有效
+	reopened, err := NewFile(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := reopened.Get("k"); !ok || string(got.Value) != "三体" {
+		t.Errorf("重新打开后 Get = %v, %v", got, ok)
+	}
+}
+
+func TestFileGetRemovesCorrupt(t *testing.T) {
+	f, err := NewFile(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Set("k", testEntry("v"))
+	if err := os.WriteFile(filepath.Join(f.dir, fileName("k")), []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := f.Get("k"); ok {
+		t.Error("损坏的文件不应返回")
+	}
+	if n, _ := f.Stats(); n != 0 {
+		t.Errorf("损坏的条目应被删除，剩余 %d 条", n)
+	}
+}
+
+func TestFileEviction(t *testing.T) {
+	f, err := NewFile(t.TempDir(), 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Set("a", testEntry("1"))
+	f.Set("b", testEntry("2"))
+	f.Get("a")
+	f.Set("c", testEntry("3"))
+
+	if _, ok := f.Get("b"); ok {
+		t.Error("最久未使用的 b 应被淘汰")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := f.Get(key); !ok {
+			t.Errorf("%s 不应被淘汰", key)
+		}
+	}
+}
+
+func TestFileConcurrentAccess(t *testing.T) {
+	f, err := NewFile(t.TempDir(), 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				key := fmt.Sprintf("k%d", j%6)
+				value := fmt.Sprintf("%s-%d", key, i)
+				if j%3 == 0 {
+					f.Set(key, testEntry(value))
+				} else if e, ok := f.Get(key); ok && !strings.HasPrefix(string(e.Value), key+"-") {
+					t.Errorf("%s 读到了错误的值 %q", key, e.Value)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n, _ := f.Stats(); n > 4 {
+		t.Errorf("条目数 %d 超过上限", n)
+	}
+	entries, _ := os.ReadDir(f.dir)
+	if len(entries) > 4 {
+		t.Errorf("目录中有 %d 个文件", len(entries))
+	}
+}
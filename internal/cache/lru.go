@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU 有条目数和字节数上限的内存缓存，超出时淘汰最久未使用的条目
+type LRU struct {
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	ll         *list.List               // 最近使用的在前
+	items      map[string]*list.Element // key -> 链表节点
+}
+
+type lruItem struct {
+	key   string
+	entry *Entry
+}
+
+// NewLRU 创建内存LRU缓存，maxEntries 或 maxBytes 为0表示不限制该项
+func NewLRU(maxEntries int, maxBytes int64) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get 获取条目并标记为最近使用
+func (l *LRU) Get(key string) (*Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.ll.MoveToFront(el)
+	return el.Value.(*lruItem).entry, true
+}
+
+// Set 保存条目，单个条目超过 maxBytes 时不缓存
+func (l *LRU) Set(key string, e *Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	size := e.size(key)
+	if l.maxBytes > 0 && size > l.maxBytes {
+		l.remove(key)
+		return
+	}
+
+	if el, ok := l.items[key]; ok {
+		item := el.Value.(*lruItem)
+		l.bytes += size - item.entry.size(key)
+		item.entry = e
+		l.ll.MoveToFront(el)
+	} else {
+		l.items[key] = l.ll.PushFront(&lruItem{key: key, entry: e})
+		l.bytes += size
+	}
+
+	for l.overflow() {
+		l.removeElement(l.ll.Back())
+	}
+}
+
+// overflow 判断是否超出容量，调用方需持有锁
+func (l *LRU) overflow() bool {
+	if l.ll.Len() == 0 {
+		return false
+	}
+	return (l.maxEntries > 0 && l.ll.Len() > l.maxEntries) ||
+		(l.maxBytes > 0 && l.bytes > l.maxBytes)
+}
+
+// Delete 删除条目
+func (l *LRU) Delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.remove(key)
+}
+
+func (l *LRU) remove(key string) {
+	if el, ok := l.items[key]; ok {
+		l.removeElement(el)
+	}
+}
+
+func (l *LRU) removeElement(el *list.Element) {
+	item := el.Value.(*lruItem)
+	l.ll.Remove(el)
+	delete(l.items, item.key)
+	l.bytes -= item.entry.size(item.key)
+}
+
+// Purge 删除超过旧数据期限的条目
+func (l *LRU) Purge(before time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := 0
+	for el := l.ll.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*lruItem).entry.StaleUntil.Before(before) {
+			l.removeElement(el)
+			n++
+		}
+		el = next
+	}
+	return n
+}
+
+// Stats 返回条目数和占用字节数
+func (l *LRU) Stats() (int, int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len(), l.bytes
+}
+
+// Close 清空缓存
+func (l *LRU) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ll.Init()
+	l.items = make(map[string]*list.Element)
+	l.bytes = 0
+	return nil
+}
@@ -0,0 +1,104 @@
+package config
+
+import "testing"
+
+func TestValidateTelegramProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		global   string
+		telegram string
+		want     string // 验证后的 proxy.url，为空表示应返回错误
+	}{
+		{"只设置全局代理", "socks5://127.0.0.1:1080", "", "socks5://127.0.0.1:1080"},
+		{"只设置TG代理时作为全局代理", "", "socks5://127.0.0.1:1080", "socks5://127.0.0.1:1080"},
+		{"两者相同", "http://127.0.0.1:8080", "http://127.0.0.1:8080", "http://127.0.0.1:8080"},
+		{"两者不同", "socks5://127.0.0.1:1080", "http://127.0.0.1:8080", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.Server.Port = 8888
+			cfg.Proxy.URL = tt.global
+			cfg.Telegram.Proxy = tt.telegram
+
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err == nil {
+					t.Fatal("两个代理地址不同时应返回错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Proxy.URL != tt.want {
+				t.Errorf("proxy.url = %q, want %q", cfg.Proxy.URL, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateMergesPartialSettings(t *testing.T) {
+	cfg := &Config{}
+	cfg.Server.Port = 8888
+	cfg.Search.Timeout = 30
+	cfg.Telegram.Channels = []string{"a", "b"}
+	cfg.Plugins.List = map[string]PluginSettings{
+		"xys":  {Enabled: true, Priority: 2, Proxy: "auto"},
+		"labi": {Enabled: true, Priority: 1},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := cfg.Update(map[string]interface{}{
+		"plugins": map[string]interface{}{
+			"list": map[string]interface{}{
+				"xys": map[string]interface{}{"enabled": false},
+			},
+		},
+		"search":   map[string]interface{}{"concurrency": 8},
+		"telegram": map[string]interface{}{"channels": []interface{}{"c"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := updated.Plugins.List["xys"]; got != (PluginSettings{Enabled: false, Priority: 2, Proxy: "auto"}) {
+		t.Errorf("xys = %+v，优先级和代理设置不应被清空", got)
+	}
+	if got := updated.Plugins.List["labi"]; got != (PluginSettings{Enabled: true, Priority: 1}) {
+		t.Errorf("labi = %+v，未修改的插件不应变化", got)
+	}
+	if updated.Search.Concurrency != 8 || updated.Search.Timeout != 30 {
+		t.Errorf("search = %+v", updated.Search)
+	}
+	if len(updated.Telegram.Channels) != 1 || updated.Telegram.Channels[0] != "c" {
+		t.Errorf("列表应整体替换: %v", updated.Telegram.Channels)
+	}
+
+	// 原配置不受影响
+	if !cfg.Plugins.List["xys"].Enabled || cfg.Search.Concurrency == 8 || len(cfg.Telegram.Channels) != 2 {
+		t.Error("Update 修改了原配置")
+	}
+}
+
+func TestUpdateRejectsInvalid(t *testing.T) {
+	cfg := &Config{}
+	cfg.Server.Port = 8888
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, updates := range []map[string]interface{}{
+		{"search": map[string]interface{}{"no_such_field": 1}},
+		{"server": map[string]interface{}{"port": 0}},
+		{"search": map[string]interface{}{"validate_links": "sometimes"}},
+		{"plugins": map[string]interface{}{"list": map[string]interface{}{"xys": map[string]interface{}{"proxy": "tor"}}}},
+	} {
+		if _, err := cfg.Update(updates); err == nil {
+			t.Errorf("Update(%v) 应返回错误", updates)
+		}
+	}
+}
@@ -0,0 +1,507 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"pansou-openwrt/internal/fsutil"
+)
+
+// Source 配置来源
+// 设置了 UCIPath 时以UCI配置（/etc/config/pansou）为基础，YAML文件作为覆盖层，其中出现的字段优先；
+// 否则只读取YAML文件
+type Source struct {
+	Path    string // YAML配置文件，使用UCI时不存在也可以
+	UCIPath string // UCI配置文件，为空时不使用UCI
+
+	// PluginEnabled 返回插件的默认开关，UCI中只设置了优先级或代理模式的插件使用此默认值
+	PluginEnabled func(name string) bool
+}
+
+// defaultCloudTypes UCI中未出现的网盘类型默认启用
+var defaultCloudTypes = append(append([]string(nil), KnownCloudTypes...), OtherCloudType)
+
+// Files 返回参与加载的配置文件
+func (s *Source) Files() []string {
+	if s.UCIPath == "" {
+		return []string{s.Path}
+	}
+	return []string{s.UCIPath, s.Path}
+}
+
+// ErrOverlayField 要修改的配置项由YAML覆盖层设置，写入UCI后不会生效
+var ErrOverlayField = errors.New("以下配置项由配置文件设置，请在配置文件中修改")
+
+// Load 加载并验证配置
+func (s *Source) Load() (*Config, error) {
+	if s.UCIPath == "" {
+		return Load(s.Path)
+	}
+
+	f, err := readUCI(s.UCIPath)
+	if err != nil {
+		return nil, err
+	}
+	overlay, err := s.readOverlay()
+	if err != nil {
+		return nil, err
+	}
+	return s.merge(f, overlay)
+}
+
+// readOverlay 读取YAML覆盖层，文件不存在时返回nil
+func (s *Source) readOverlay() ([]byte, error) {
+	if s.Path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	return data, nil
+}
+
+// decodeBase 读取UCI配置，未设置的选项使用默认值，不验证
+func (s *Source) decodeBase(f *uciFile) (*Config, error) {
+	cfg := uciDefaults()
+	if err := decodeUCI(f, cfg, s.PluginEnabled); err != nil {
+		return nil, fmt.Errorf("解析UCI配置失败: %w", err)
+	}
+	return cfg, nil
+}
+
+// merge 在UCI配置上应用YAML覆盖层并验证
+// 覆盖层逐个键合并，如只设置某个插件的 priority 时保留UCI中该插件的开关
+func (s *Source) merge(f *uciFile, overlay []byte) (*Config, error) {
+	base, err := s.decodeBase(f)
+	if err != nil {
+		return nil, err
+	}
+	merged, err := base.toMap()
+	if err != nil {
+		return nil, err
+	}
+
+	var layer map[string]interface{}
+	if err := yaml.Unmarshal(overlay, &layer); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	mergeMaps(merged, layer)
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Save 保存配置：使用UCI时写回UCI文件（保留其中的其他选项），否则写入YAML文件
+// YAML覆盖层中出现的配置项写入UCI后不会生效：修改这些项时返回 ErrOverlayField，
+// 未修改时UCI中保留原来的值，覆盖层的值不会被复制到UCI
+func (s *Source) Save(cfg *Config) error {
+	if s.UCIPath == "" {
+		return cfg.Save(s.Path)
+	}
+
+	f, err := readUCI(s.UCIPath)
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = &uciFile{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	overlay, err := s.readOverlay()
+	if err != nil {
+		return err
+	}
+	if len(overlay) > 0 {
+		if cfg, err = s.withoutOverlay(f, overlay, cfg); err != nil {
+			return err
+		}
+	}
+
+	encodeUCI(f, cfg)
+	if err := fsutil.WriteFileAtomic(s.UCIPath, f.Bytes(), 0644); err != nil {
+		return fmt.Errorf("写入UCI配置失败: %w", err)
+	}
+	return nil
+}
+
+// withoutOverlay 返回要写入UCI的配置：覆盖层中出现的配置项恢复为UCI中原来的值
+// 这些配置项与当前生效的值不同（即要修改它们）时返回 ErrOverlayField
+func (s *Source) withoutOverlay(f *uciFile, overlay []byte, cfg *Config) (*Config, error) {
+	var layer map[string]interface{}
+	if err := yaml.Unmarshal(overlay, &layer); err != nil {
+		return nil, fmt.Errorf("解析配置文件失败: %w", err)
+	}
+
+	current, err := s.merge(f, overlay)
+	if err != nil {
+		return nil, err
+	}
+	base, err := s.decodeBase(f)
+	if err != nil {
+		return nil, err
+	}
+
+	want, err := cfg.toMap()
+	if err != nil {
+		return nil, err
+	}
+	currentMap, err := current.toMap()
+	if err != nil {
+		return nil, err
+	}
+	baseMap, err := base.toMap()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, path := range leafPaths(layer, nil) {
+		value, _ := lookupPath(want, path)
+		old, _ := lookupPath(currentMap, path)
+		if !reflect.DeepEqual(value, old) {
+			changed = append(changed, strings.Join(path, "."))
+			continue
+		}
+		if value, ok := lookupPath(baseMap, path); ok {
+			setPath(want, path, value)
+		} else {
+			deletePath(want, path)
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return nil, fmt.Errorf("%w（%s）: %s", ErrOverlayField, s.Path, strings.Join(changed, ", "))
+	}
+
+	return fromMap(want)
+}
+
+// leafPaths 返回映射中所有非映射值的键路径，值为空（YAML中只写了键）的项不会覆盖任何配置，跳过
+func leafPaths(m map[string]interface{}, prefix []string) [][]string {
+	var paths [][]string
+	for key, value := range m {
+		path := append(append([]string(nil), prefix...), key)
+		switch v := value.(type) {
+		case nil:
+		case map[string]interface{}:
+			paths = append(paths, leafPaths(v, path)...)
+		default:
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// lookupPath 按键路径查找映射中的值
+func lookupPath(m map[string]interface{}, path []string) (interface{}, bool) {
+	for i, key := range path {
+		value, ok := m[key]
+		if !ok {
+			return nil, false
+		}
+		if i == len(path)-1 {
+			return value, true
+		}
+		if m, ok = value.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// setPath 按键路径设置映射中的值，中间的映射不存在时创建
+func setPath(m map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		sub, ok := m[key].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[key] = sub
+		}
+		m = sub
+	}
+	m[path[len(path)-1]] = value
+}
+
+// deletePath 按键路径删除映射中的值
+func deletePath(m map[string]interface{}, path []string) {
+	for _, key := range path[:len(path)-1] {
+		sub, ok := m[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = sub
+	}
+	delete(m, path[len(path)-1])
+}
+
+// uciDefaults 返回UCI中未设置的选项的默认值，与 files/pansou.config 一致
+func uciDefaults() *Config {
+	return &Config{
+		Server:     ServerConfig{Port: 8888, Enabled: true, Autostart: true},
+		Search:     SearchConfig{CacheStale: 1440},
+		Telegram:   TelegramConfig{Enabled: true, CrawlInterval: 10},
+		Plugins:    PluginsConfig{Enabled: true},
+		Proxy:      ProxyConfig{Mode: "direct"},
+		CloudTypes: CloudTypesConfig{Enabled: append([]string(nil), defaultCloudTypes...)},
+		Logging:    LoggingConfig{Level: "info", File: "/var/log/pansou.log"},
+	}
+}
+
+// uciSectionType 返回配置节对应的UCI节类型，server 对应 config 节，其余与YAML键名相同
+func uciSectionType(key string) string {
+	if key == "server" {
+		return "config"
+	}
+	return key
+}
+
+// yamlKey 返回结构体字段的YAML键名
+func yamlKey(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// decodeUCI 按YAML键名将UCI选项映射到配置，新增的配置字段无需额外代码即可从UCI读取
+// 插件（plugin_/priority_/proxy_前缀）和网盘类型（type_前缀）使用UCI特有的写法，单独处理
+func decodeUCI(f *uciFile, cfg *Config, pluginEnabled func(string) bool) error {
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key := yamlKey(v.Type().Field(i))
+		sec := f.section(uciSectionType(key))
+		if sec == nil {
+			continue
+		}
+
+		if key == "cloud_types" {
+			decodeCloudTypes(sec, &cfg.CloudTypes)
+			continue
+		}
+		if err := decodeSection(sec, v.Field(i)); err != nil {
+			return fmt.Errorf("%s.%w", sec.Type, err)
+		}
+		if key == "plugins" {
+			decodePlugins(sec, &cfg.Plugins, pluginEnabled)
+		}
+	}
+	return nil
+}
+
+// decodeSection 读取结构体中的 bool、int、string 和 []string 字段
+func decodeSection(sec *uciSection, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		name := yamlKey(v.Type().Field(i))
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String {
+			if list := sec.getList(name); len(list) > 0 {
+				field.Set(reflect.ValueOf(list))
+			} else if value, ok := sec.get(name); ok {
+				field.Set(reflect.ValueOf(strings.Fields(value)))
+			}
+			continue
+		}
+
+		value, ok := sec.get(name)
+		if !ok {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.Bool:
+			b, err := parseUCIBool(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			field.SetBool(b)
+		case reflect.Int:
+			if value == "" {
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s: 无效的数值 %q", name, value)
+			}
+			field.SetInt(int64(n))
+		case reflect.String:
+			field.SetString(value)
+		}
+	}
+	return nil
+}
+
+// decodePlugins 读取单个插件的设置：plugin_<名称>、priority_<名称>、proxy_<名称>
+func decodePlugins(sec *uciSection, plugins *PluginsConfig, pluginEnabled func(string) bool) {
+	for _, name := range sec.names() {
+		prefix, plugin, ok := strings.Cut(name, "_")
+		if !ok || plugin == "" {
+			continue
+		}
+		if prefix != "plugin" && prefix != "priority" && prefix != "proxy" {
+			continue
+		}
+
+		if plugins.List == nil {
+			plugins.List = make(map[string]PluginSettings)
+		}
+		settings, exists := plugins.List[plugin]
+		if !exists {
+			settings.Enabled = true
+			if pluginEnabled != nil {
+				settings.Enabled = pluginEnabled(plugin)
+			}
+		}
+
+		value, _ := sec.get(name)
+		switch prefix {
+		case "plugin":
+			if b, err := parseUCIBool(value); err == nil {
+				settings.Enabled = b
+			}
+		case "priority":
+			settings.Priority, _ = strconv.Atoi(value)
+		case "proxy":
+			settings.Proxy = value
+		}
+		plugins.List[plugin] = settings
+	}
+}
+
+// decodeCloudTypes 读取 type_<类型> 开关，未出现的已知类型保持启用
+func decodeCloudTypes(sec *uciSection, cloudTypes *CloudTypesConfig) {
+	disabled := make(map[string]bool)
+	enabled := make([]string, 0, len(defaultCloudTypes))
+	for _, name := range sec.names() {
+		typ, ok := strings.CutPrefix(name, "type_")
+		if !ok || typ == "" {
+			continue
+		}
+		value, _ := sec.get(name)
+		if b, err := parseUCIBool(value); err == nil && !b {
+			disabled[typ] = true
+		} else {
+			enabled = append(enabled, typ)
+		}
+	}
+
+	for _, typ := range defaultCloudTypes {
+		if !disabled[typ] && !contains(enabled, typ) {
+			enabled = append(enabled, typ)
+		}
+	}
+	cloudTypes.Enabled = enabled
+}
+
+// encodeUCI 将配置写入UCI文件，已有的节和选项原位更新
+func encodeUCI(f *uciFile, cfg *Config) {
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key := yamlKey(v.Type().Field(i))
+		sec := f.ensureSection(uciSectionType(key))
+
+		if key == "cloud_types" {
+			encodeCloudTypes(sec, &cfg.CloudTypes)
+			continue
+		}
+		encodeSection(sec, v.Field(i))
+		if key == "plugins" {
+			encodePlugins(sec, &cfg.Plugins)
+		}
+	}
+}
+
+// encodeSection 写入结构体中的 bool、int、string 和 []string 字段
+func encodeSection(sec *uciSection, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		name := yamlKey(v.Type().Field(i))
+		field := v.Field(i)
+
+		switch field.Kind() {
+		case reflect.Bool:
+			sec.set(name, formatUCIBool(field.Bool()))
+		case reflect.Int:
+			sec.set(name, strconv.FormatInt(field.Int(), 10))
+		case reflect.String:
+			sec.set(name, field.String())
+		case reflect.Slice:
+			if field.Type().Elem().Kind() == reflect.String {
+				sec.setList(name, field.Interface().([]string))
+			}
+		}
+	}
+}
+
+// encodePlugins 写入单个插件的设置，未设置的优先级和代理模式删除对应选项
+func encodePlugins(sec *uciSection, plugins *PluginsConfig) {
+	for name, settings := range plugins.List {
+		sec.set("plugin_"+name, formatUCIBool(settings.Enabled))
+		if settings.Priority > 0 {
+			sec.set("priority_"+name, strconv.Itoa(settings.Priority))
+		} else {
+			sec.remove("priority_" + name)
+		}
+		if settings.Proxy != "" {
+			sec.set("proxy_"+name, settings.Proxy)
+		} else {
+			sec.remove("proxy_" + name)
+		}
+	}
+}
+
+// encodeCloudTypes 写入每个网盘类型的开关
+func encodeCloudTypes(sec *uciSection, cloudTypes *CloudTypesConfig) {
+	for _, typ := range defaultCloudTypes {
+		sec.set("type_"+typ, formatUCIBool(contains(cloudTypes.Enabled, typ)))
+	}
+	for _, typ := range cloudTypes.Enabled {
+		sec.set("type_"+typ, "1")
+	}
+}
+
+// parseUCIBool 解析UCI布尔值
+func parseUCIBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "true", "yes", "on", "enabled":
+		return true, nil
+	case "0", "false", "no", "off", "disabled":
+		return false, nil
+	}
+	return false, fmt.Errorf("无效的布尔值 %q", s)
+}
+
+func formatUCIBool(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
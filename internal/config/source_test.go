@@ -0,0 +1,162 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSource 在临时目录中写入UCI配置和YAML覆盖层
+func writeSource(t *testing.T, uci, overlay string) *Source {
+	t.Helper()
+
+	dir := t.TempDir()
+	s := &Source{
+		Path:    filepath.Join(dir, "config.yaml"),
+		UCIPath: filepath.Join(dir, "pansou"),
+	}
+	if err := os.WriteFile(s.UCIPath, []byte(uci), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if overlay != "" {
+		if err := os.WriteFile(s.Path, []byte(overlay), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+const sourceUCI = `config config 'config'
+	option port '8888'
+
+config search 'search'
+	option timeout '30'
+	option concurrency '5'
+
+config plugins 'plugins'
+	option enabled '1'
+	option plugin_xys '1'
+	option priority_xys '3'
+`
+
+const sourceOverlay = `search:
+  timeout: 10
+plugins:
+  list:
+    xys:
+      priority: 1
+logging:
+`
+
+func TestSourceLoadOverlay(t *testing.T) {
+	s := writeSource(t, sourceUCI, sourceOverlay)
+	cfg, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Search.Timeout != 10 || cfg.Search.Concurrency != 5 {
+		t.Errorf("search = %+v", cfg.Search)
+	}
+	if got := cfg.Plugins.List["xys"]; got != (PluginSettings{Enabled: true, Priority: 1}) {
+		t.Errorf("xys = %+v", got)
+	}
+}
+
+func TestSourceSaveKeepsOverlayOutOfUCI(t *testing.T) {
+	s := writeSource(t, sourceUCI, sourceOverlay)
+	cfg, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := cfg.Update(map[string]interface{}{
+		"search":  map[string]interface{}{"concurrency": 8},
+		"plugins": map[string]interface{}{"list": map[string]interface{}{"xys": map[string]interface{}{"enabled": false}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(updated); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(s.UCIPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uci := string(data)
+	for _, want := range []string{
+		"option timeout '30'", // 覆盖层的值不写入UCI
+		"option priority_xys '3'",
+		"option concurrency '8'",
+		"option plugin_xys '0'",
+	} {
+		if !strings.Contains(uci, want) {
+			t.Errorf("UCI中缺少 %q:\n%s", want, uci)
+		}
+	}
+
+	// 重新加载后修改仍然生效
+	reloaded, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Search.Concurrency != 8 || reloaded.Search.Timeout != 10 {
+		t.Errorf("search = %+v", reloaded.Search)
+	}
+	if got := reloaded.Plugins.List["xys"]; got != (PluginSettings{Enabled: false, Priority: 1}) {
+		t.Errorf("xys = %+v", got)
+	}
+}
+
+func TestSourceSaveRejectsOverlayFields(t *testing.T) {
+	s := writeSource(t, sourceUCI, sourceOverlay)
+	cfg, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(s.UCIPath)
+
+	for _, updates := range []map[string]interface{}{
+		{"search": map[string]interface{}{"timeout": 20}},
+		{"plugins": map[string]interface{}{"list": map[string]interface{}{"xys": map[string]interface{}{"priority": 2}}}},
+	} {
+		updated, err := cfg.Update(updates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Save(updated); !errors.Is(err, ErrOverlayField) {
+			t.Errorf("Save(%v) error = %v, want ErrOverlayField", updates, err)
+		}
+	}
+
+	after, _ := os.ReadFile(s.UCIPath)
+	if string(after) != string(before) {
+		t.Errorf("拒绝修改时不应写入UCI:\n%s", after)
+	}
+}
+
+func TestSourceSaveWithoutOverlay(t *testing.T) {
+	s := writeSource(t, sourceUCI, "")
+	cfg, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, err := cfg.Update(map[string]interface{}{"search": map[string]interface{}{"timeout": 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(updated); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Search.Timeout != 20 {
+		t.Errorf("timeout = %d", reloaded.Search.Timeout)
+	}
+}
@@ -0,0 +1,272 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// uciFile UCI配置文件（/etc/config/pansou），保留节和选项的顺序以便原样写回
+type uciFile struct {
+	sections []*uciSection
+}
+
+// uciSection UCI配置节，如 config search 'search'
+type uciSection struct {
+	Type    string
+	Name    string
+	Options []uciOption
+}
+
+// uciOption option 或 list 条目，list 同名条目可以有多个
+type uciOption struct {
+	List  bool
+	Name  string
+	Value string
+}
+
+// readUCI 读取并解析UCI配置文件
+func readUCI(path string) (*uciFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取UCI配置失败: %w", err)
+	}
+	f, err := parseUCI(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析UCI配置失败: %w", err)
+	}
+	return f, nil
+}
+
+// parseUCI 解析UCI语法：config/option/list 行，支持单双引号和 # 注释
+func parseUCI(data []byte) (*uciFile, error) {
+	f := &uciFile{}
+	var current *uciSection
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		tokens, err := splitUCILine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", lineNum, err)
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+
+		switch tokens[0] {
+		case "package":
+		case "config":
+			if len(tokens) < 2 || len(tokens) > 3 {
+				return nil, fmt.Errorf("第 %d 行: config 格式错误", lineNum)
+			}
+			current = &uciSection{Type: tokens[1]}
+			if len(tokens) == 3 {
+				current.Name = tokens[2]
+			}
+			f.sections = append(f.sections, current)
+		case "option", "list":
+			if current == nil {
+				return nil, fmt.Errorf("第 %d 行: %s 不在任何 config 节中", lineNum, tokens[0])
+			}
+			if len(tokens) < 2 || len(tokens) > 3 {
+				return nil, fmt.Errorf("第 %d 行: %s 格式错误", lineNum, tokens[0])
+			}
+			opt := uciOption{List: tokens[0] == "list", Name: tokens[1]}
+			if len(tokens) == 3 {
+				opt.Value = tokens[2]
+			}
+			current.Options = append(current.Options, opt)
+		default:
+			return nil, fmt.Errorf("第 %d 行: 无法识别的关键字 %s", lineNum, tokens[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// splitUCILine 按shell规则拆分一行：空白分隔，'...' 原样保留，"..." 支持反斜杠转义，
+// 相邻的引号部分拼接为一个词，# 开始注释
+func splitUCILine(line string) ([]string, error) {
+	var tokens []string
+	var buf strings.Builder
+	inToken := false
+
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			if inToken {
+				tokens = append(tokens, buf.String())
+				buf.Reset()
+				inToken = false
+			}
+		case ch == '#' && !inToken:
+			return tokens, nil
+		case ch == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("单引号未闭合")
+			}
+			buf.WriteString(line[i+1 : i+1+end])
+			i += end + 1
+			inToken = true
+		case ch == '"':
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				buf.WriteByte(line[i])
+			}
+			if i >= len(line) {
+				return nil, fmt.Errorf("双引号未闭合")
+			}
+			inToken = true
+		case ch == '\\' && i+1 < len(line):
+			i++
+			buf.WriteByte(line[i])
+			inToken = true
+		default:
+			buf.WriteByte(ch)
+			inToken = true
+		}
+	}
+	if inToken {
+		tokens = append(tokens, buf.String())
+	}
+	return tokens, nil
+}
+
+// Bytes 按UCI格式输出，值统一使用单引号
+func (f *uciFile) Bytes() []byte {
+	var buf bytes.Buffer
+	for i, sec := range f.sections {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("config " + sec.Type)
+		if sec.Name != "" {
+			buf.WriteString(" " + quoteUCI(sec.Name))
+		}
+		buf.WriteString("\n")
+		for _, opt := range sec.Options {
+			keyword := "option"
+			if opt.List {
+				keyword = "list"
+			}
+			fmt.Fprintf(&buf, "\t%s %s %s\n", keyword, opt.Name, quoteUCI(opt.Value))
+		}
+	}
+	return buf.Bytes()
+}
+
+// quoteUCI 用单引号包裹值，值中的单引号先结束引号再转义
+func quoteUCI(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// section 返回指定类型的第一个节，不存在时返回nil
+func (f *uciFile) section(typ string) *uciSection {
+	for _, sec := range f.sections {
+		if sec.Type == typ {
+			return sec
+		}
+	}
+	return nil
+}
+
+// ensureSection 返回指定类型的第一个节，不存在时新建一个同名的节
+func (f *uciFile) ensureSection(typ string) *uciSection {
+	if sec := f.section(typ); sec != nil {
+		return sec
+	}
+	sec := &uciSection{Type: typ, Name: typ}
+	f.sections = append(f.sections, sec)
+	return sec
+}
+
+// get 返回 option 的值
+func (s *uciSection) get(name string) (string, bool) {
+	for _, opt := range s.Options {
+		if !opt.List && opt.Name == name {
+			return opt.Value, true
+		}
+	}
+	return "", false
+}
+
+// getList 返回 list 的所有值
+func (s *uciSection) getList(name string) []string {
+	var values []string
+	for _, opt := range s.Options {
+		if opt.List && opt.Name == name {
+			values = append(values, opt.Value)
+		}
+	}
+	return values
+}
+
+// set 设置 option 的值，已存在时原位替换
+func (s *uciSection) set(name, value string) {
+	for i, opt := range s.Options {
+		if !opt.List && opt.Name == name {
+			s.Options[i].Value = value
+			return
+		}
+	}
+	s.Options = append(s.Options, uciOption{Name: name, Value: value})
+}
+
+// setList 替换 list 的所有值，新值放在原来第一个条目的位置
+func (s *uciSection) setList(name string, values []string) {
+	pos := -1
+	options := make([]uciOption, 0, len(s.Options)+len(values))
+	for _, opt := range s.Options {
+		if opt.Name == name {
+			if pos < 0 {
+				pos = len(options)
+			}
+			continue
+		}
+		options = append(options, opt)
+	}
+	if pos < 0 {
+		pos = len(options)
+	}
+
+	items := make([]uciOption, 0, len(values))
+	for _, v := range values {
+		items = append(items, uciOption{List: true, Name: name, Value: v})
+	}
+	s.Options = append(options[:pos], append(items, options[pos:]...)...)
+}
+
+// remove 删除同名的 option 和 list 条目
+func (s *uciSection) remove(name string) {
+	options := s.Options[:0]
+	for _, opt := range s.Options {
+		if opt.Name != name {
+			options = append(options, opt)
+		}
+	}
+	s.Options = options
+}
+
+// names 返回节中出现过的选项名（去重，保持顺序）
+func (s *uciSection) names() []string {
+	seen := make(map[string]bool)
+	names := make([]string, 0, len(s.Options))
+	for _, opt := range s.Options {
+		if !seen[opt.Name] {
+			seen[opt.Name] = true
+			names = append(names, opt.Name)
+		}
+	}
+	return names
+}
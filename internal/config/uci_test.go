@@ -0,0 +1,249 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testUCI = `# /etc/config/pansou
+package pansou
+
+config config 'config'
+	option enabled '1'
+	option port "9090"   # 行尾注释
+
+config search search
+	option timeout '20'
+	option cache_dir '/mnt/sda1/it'\''s cache'
+	option validate_links "fl\"ag"
+
+config telegram 'telegram'
+	list channels 'a'
+	list channels "b c"
+	option bot_token ''
+
+config plugins 'plugins'
+	option enabled '0'
+	option plugin_xys '0'
+	option priority_xys '2'
+	option proxy_labi 'auto'
+	option custom 'keep'
+
+config cloud_types 'cloud_types'
+	option type_baidu '0'
+	option type_lanzou 'yes'
+	option type_newtype '1'
+`
+
+func TestParseUCI(t *testing.T) {
+	f, err := parseUCI([]byte(testUCI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		typ, name string
+		options   []uciOption
+	}{
+		{"config", "config", []uciOption{{Name: "enabled", Value: "1"}, {Name: "port", Value: "9090"}}},
+		{"search", "search", []uciOption{
+			{Name: "timeout", Value: "20"},
+			{Name: "cache_dir", Value: "/mnt/sda1/it's cache"},
+			{Name: "validate_links", Value: `fl"ag`},
+		}},
+		{"telegram", "telegram", []uciOption{
+			{List: true, Name: "channels", Value: "a"},
+			{List: true, Name: "channels", Value: "b c"},
+			{Name: "bot_token", Value: ""},
+		}},
+	}
+	if len(f.sections) != 5 {
+		t.Fatalf("解析出 %d 个节, want 5", len(f.sections))
+	}
+	for i, w := range want {
+		sec := f.sections[i]
+		if sec.Type != w.typ || sec.Name != w.name || !reflect.DeepEqual(sec.Options, w.options) {
+			t.Errorf("第 %d 节 = %+v, want %+v", i, *sec, w)
+		}
+	}
+
+	// 输出后重新解析得到相同的内容
+	again, err := parseUCI(f.Bytes())
+	if err != nil {
+		t.Fatalf("解析输出失败: %v\n%s", err, f.Bytes())
+	}
+	if !reflect.DeepEqual(again.sections, f.sections) {
+		t.Errorf("重新解析结果不同:\n%s", f.Bytes())
+	}
+}
+
+func TestParseUCIErrors(t *testing.T) {
+	for _, data := range []string{
+		"option port '8888'",
+		"config search 'search'\n\toption timeout '30",
+		"config search 'search'\n\toption timeout \"30",
+		"config",
+		"config search 'search'\n\toption",
+		"config search 'search'\n\tset timeout 30",
+	} {
+		if _, err := parseUCI([]byte(data)); err == nil {
+			t.Errorf("parseUCI(%q) 应返回错误", data)
+		}
+	}
+}
+
+func TestDecodeUCI(t *testing.T) {
+	f, err := parseUCI([]byte(testUCI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := uciDefaults()
+	pluginEnabled := func(name string) bool { return name != "labi" }
+	if err := decodeUCI(f, cfg, pluginEnabled); err != nil {
+		t.Fatal(err)
+	}
+
+	// server 字段来自 config 节，未设置的选项保留默认值
+	if cfg.Server != (ServerConfig{Port: 9090, Enabled: true, Autostart: true}) {
+		t.Errorf("server = %+v", cfg.Server)
+	}
+	if cfg.Search.Timeout != 20 || cfg.Search.CacheDir != "/mnt/sda1/it's cache" || cfg.Search.CacheStale != 1440 {
+		t.Errorf("search = %+v", cfg.Search)
+	}
+	if !reflect.DeepEqual(cfg.Telegram.Channels, []string{"a", "b c"}) {
+		t.Errorf("channels = %v", cfg.Telegram.Channels)
+	}
+	if cfg.Plugins.Enabled {
+		t.Error("plugins.enabled 应为false")
+	}
+	wantPlugins := map[string]PluginSettings{
+		"xys":  {Enabled: false, Priority: 2},
+		"labi": {Enabled: false, Proxy: "auto"}, // 只设置了代理模式时使用插件的默认开关
+	}
+	if !reflect.DeepEqual(cfg.Plugins.List, wantPlugins) {
+		t.Errorf("plugins.list = %+v", cfg.Plugins.List)
+	}
+
+	enabled := cfg.CloudTypes.Enabled
+	if contains(enabled, "baidu") || !contains(enabled, "lanzou") || !contains(enabled, "newtype") ||
+		!contains(enabled, "quark") || !contains(enabled, OtherCloudType) {
+		t.Errorf("cloud_types = %v", enabled)
+	}
+}
+
+func TestDecodeUCIErrors(t *testing.T) {
+	for _, data := range []string{
+		"config config 'config'\n\toption port 'abc'",
+		"config search 'search'\n\toption timeout '1.5'",
+		"config telegram 'telegram'\n\toption enabled 'maybe'",
+	} {
+		f, err := parseUCI([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := decodeUCI(f, uciDefaults(), nil); err == nil {
+			t.Errorf("decodeUCI(%q) 应返回错误", data)
+		}
+	}
+}
+
+func TestEncodeUCIRoundTrip(t *testing.T) {
+	f, err := parseUCI([]byte(testUCI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := uciDefaults()
+	if err := decodeUCI(f, cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Server.Port = 8080
+	cfg.Telegram.Channels = []string{"x"}
+	cfg.Plugins.List["xys"] = PluginSettings{Enabled: true}
+	cfg.CloudTypes.Enabled = []string{"quark", "newtype"}
+	encodeUCI(f, cfg)
+
+	data := string(f.Bytes())
+	for _, want := range []string{
+		"config config 'config'\n\toption enabled '1'\n\toption port '8080'\n",
+		"\tlist channels 'x'\n\toption bot_token ''\n",
+		"\toption custom 'keep'\n", // 未知选项原样保留
+		"\toption cache_dir '/mnt/sda1/it'\\''s cache'\n",
+		"\toption type_baidu '0'\n",
+		"\toption type_newtype '1'\n",
+	} {
+		if !strings.Contains(data, want) {
+			t.Errorf("输出中缺少 %q:\n%s", want, data)
+		}
+	}
+	// 已删除的优先级不再写入
+	if strings.Contains(data, "priority_xys") {
+		t.Errorf("priority_xys 应被删除:\n%s", data)
+	}
+	if strings.Contains(data, "config server") {
+		t.Errorf("server 应写入 config 节:\n%s", data)
+	}
+
+	reparsed, err := parseUCI(f.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := uciDefaults()
+	if err := decodeUCI(reparsed, decoded, nil); err != nil {
+		t.Fatal(err)
+	}
+	// 网盘类型按UCI中出现的顺序读回
+	sort.Strings(decoded.CloudTypes.Enabled)
+	sort.Strings(cfg.CloudTypes.Enabled)
+	if !reflect.DeepEqual(decoded, cfg) {
+		t.Errorf("重新读取的配置不同:\n got: %+v\nwant: %+v", decoded, cfg)
+	}
+}
+
+func TestCloudTypesRoundTrip(t *testing.T) {
+	tests := [][]string{
+		{"baidu", "quark"},
+		{},
+		append([]string{"newtype"}, defaultCloudTypes...),
+	}
+
+	for _, enabled := range tests {
+		sec := &uciSection{Type: "cloud_types"}
+		encodeCloudTypes(sec, &CloudTypesConfig{Enabled: enabled})
+
+		var got CloudTypesConfig
+		decodeCloudTypes(sec, &got)
+		if len(got.Enabled) != len(enabled) {
+			t.Errorf("%v 读回为 %v", enabled, got.Enabled)
+			continue
+		}
+		for _, typ := range enabled {
+			if !contains(got.Enabled, typ) {
+				t.Errorf("%v 读回为 %v", enabled, got.Enabled)
+				break
+			}
+		}
+	}
+
+	// 未出现的已知类型默认启用
+	var got CloudTypesConfig
+	decodeCloudTypes(&uciSection{Options: []uciOption{{Name: "type_baidu", Value: "0"}}}, &got)
+	if contains(got.Enabled, "baidu") || len(got.Enabled) != len(defaultCloudTypes)-1 {
+		t.Errorf("Enabled = %v", got.Enabled)
+	}
+}
+
+func TestUCISectionType(t *testing.T) {
+	for key, want := range map[string]string{
+		"server":      "config",
+		"search":      "search",
+		"cloud_types": "cloud_types",
+	} {
+		if got := uciSectionType(key); got != want {
+			t.Errorf("uciSectionType(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"pansou-openwrt/internal/model"
+)
+
+// proxyHealth 代理状态统计
+type proxyHealth struct {
+	mu          sync.Mutex
+	url         string
+	checked     bool
+	reachable   bool
+	lastCheck   time.Time
+	latency     time.Duration
+	checkError  string
+	successes   int64
+	failures    int64
+	lastError   string
+	lastFailure time.Time
+}
+
+// recordProbe 记录一次代理服务器连通性检查，返回可达状态是否变化
+func (h *proxyHealth) recordProbe(latency time.Duration, err error) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	prev, first := h.reachable, !h.checked
+	h.checked = true
+	h.reachable = err == nil
+	h.lastCheck = time.Now()
+	h.latency = latency
+	if err != nil {
+		h.checkError = err.Error()
+	} else {
+		h.checkError = ""
+	}
+	return first || prev != h.reachable
+}
+
+// recordRequest 记录一次经代理发出的请求
+func (h *proxyHealth) recordRequest(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err == nil {
+		h.successes++
+		return
+	}
+	h.failures++
+	h.lastError = err.Error()
+	h.lastFailure = time.Now()
+}
+
+func (h *proxyHealth) snapshot() model.ProxyHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := model.ProxyHealth{
+		URL:       h.url,
+		Reachable: h.reachable,
+		LatencyMs: h.latency.Milliseconds(),
+		Successes: h.successes,
+		Failures:  h.failures,
+		LastError: h.lastError,
+	}
+	if h.checkError != "" {
+		s.LastError = h.checkError
+	}
+	if !h.lastCheck.IsZero() {
+		t := h.lastCheck
+		s.LastCheck = &t
+	}
+	if !h.lastFailure.IsZero() {
+		t := h.lastFailure
+		s.LastFailure = &t
+	}
+	return s
+}
+
+// statsTransport 统计经代理发出的请求
+type statsTransport struct {
+	next   http.RoundTripper
+	health *proxyHealth
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if req.Context().Err() == nil {
+		t.health.recordRequest(err)
+	}
+	return resp, err
+}
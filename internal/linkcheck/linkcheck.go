@@ -0,0 +1,149 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"pansou-openwrt/internal/cache"
+	"pansou-openwrt/internal/model"
+)
+
+// Status 链接检测结果
+type Status string
+
+const (
+	StatusValid    Status = "valid"    // 分享有效
+	StatusExpired  Status = "expired"  // 分享已取消、过期或不存在
+	StatusPassword Status = "password" // 需要提取码，或链接附带的提取码不正确
+	StatusUnknown  Status = "unknown"  // 检测失败或接口返回无法识别的结果
+)
+
+// 搜索请求和配置中 validate_links 的取值
+const (
+	ModeOff  = "off"  // 不检测
+	ModeFlag = "flag" // 检测并在链接上标记 status
+	ModeDrop = "drop" // 检测并移除已失效的链接
+)
+
+const (
+	maxConcurrent = 8              // 同时检测的链接数
+	expiredTTL    = 24 * time.Hour // 已失效的链接不会恢复，结果保留更久
+	cacheEntries  = 5000           // 最多缓存的检测结果数
+	defaultTTL    = 6 * time.Hour  // 有效和需要提取码的结果默认缓存时间
+)
+
+// ValidMode 判断 validate_links 取值是否有效，空字符串表示使用默认值
+func ValidMode(mode string) bool {
+	switch mode {
+	case "", ModeOff, ModeFlag, ModeDrop:
+		return true
+	}
+	return false
+}
+
+// Checker 分享链接有效性检测器
+// 通过各网盘公开的分享信息接口判断链接状态，检测结果按链接缓存，未知结果不缓存
+type Checker struct {
+	client    *http.Client
+	endpoints Endpoints
+	ttl       atomic.Int64 // 有效和需要提取码的结果的缓存时间
+	cache     *cache.Cache
+	sem       chan struct{}
+}
+
+// New 创建检测器，endpoints 为各网盘接口地址，测试时可指向本地模拟服务
+func New(client *http.Client, endpoints Endpoints) *Checker {
+	c := &Checker{
+		client:    client,
+		endpoints: endpoints,
+		cache:     cache.New(cache.NewLRU(cacheEntries, 0)),
+		sem:       make(chan struct{}, maxConcurrent),
+	}
+	c.ttl.Store(int64(defaultTTL))
+	return c
+}
+
+// SetTTL 设置有效和需要提取码的结果的缓存时间，已缓存的结果不受影响
+func (c *Checker) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	c.ttl.Store(int64(ttl))
+}
+
+// Supports 判断是否支持检测该网盘类型
+func Supports(cloudType string) bool {
+	_, ok := probers[cloudType]
+	return ok
+}
+
+// Key 返回链接在检测结果中的标识，相同链接和提取码得到相同的值
+func Key(link model.Link) string {
+	return link.Type + "|" + link.URL + "|" + link.Password
+}
+
+// Check 检测单个链接，不支持的网盘类型返回 StatusUnknown
+func (c *Checker) Check(ctx context.Context, link model.Link) Status {
+	probe, ok := probers[link.Type]
+	if !ok {
+		return StatusUnknown
+	}
+
+	key := Key(link)
+	if value, _, ok := c.cache.Get(key); ok {
+		return Status(value)
+	}
+
+	select {
+	case c.sem <- struct{}{}:
+	case <-ctx.Done():
+		return StatusUnknown
+	}
+	status := probe(ctx, c, link)
+	<-c.sem
+
+	switch status {
+	case StatusExpired:
+		c.cache.Set(key, []byte(status), expiredTTL, 0)
+	case StatusValid, StatusPassword:
+		c.cache.Set(key, []byte(status), time.Duration(c.ttl.Load()), 0)
+	}
+	return status
+}
+
+// CheckAll 并发检测多个链接，返回 Key(link) 到检测结果的映射，不支持的网盘类型不在结果中
+// ctx 结束时尚未完成的链接记为 StatusUnknown
+func (c *Checker) CheckAll(ctx context.Context, links []model.Link) map[string]Status {
+	pending := make(map[string]model.Link)
+	for _, link := range links {
+		if Supports(link.Type) {
+			pending[Key(link)] = link
+		}
+	}
+
+	statuses := make(map[string]Status, len(pending))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for key, link := range pending {
+		wg.Add(1)
+		go func(key string, link model.Link) {
+			defer wg.Done()
+			status := c.Check(ctx, link)
+			mu.Lock()
+			statuses[key] = status
+			mu.Unlock()
+		}(key, link)
+	}
+
+	wg.Wait()
+	return statuses
+}
+
+// Close 停止缓存的后台清理
+func (c *Checker) Close() {
+	c.cache.Close()
+}
@@ -0,0 +1,332 @@
+package linkcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pansou-openwrt/internal/model"
+)
+
+// stub 模拟网盘接口的本地服务，记录收到的请求数
+type stub struct {
+	*httptest.Server
+	hits atomic.Int32
+}
+
+func newStub(t *testing.T, handler http.HandlerFunc) *stub {
+	t.Helper()
+
+	s := &stub{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	json.NewEncoder(w).Encode(v)
+}
+
+// readJSON 解析请求体中的JSON参数
+func readJSON(r *http.Request) map[string]string {
+	var body map[string]string
+	json.NewDecoder(r.Body).Decode(&body)
+	return body
+}
+
+// 各网盘的模拟接口：valid 有效，gone 已失效，locked 需要提取码 good
+// 其他分享ID返回无法识别的结果
+
+func baiduStub(t *testing.T) *stub {
+	return newStub(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/shorturlinfo":
+			errno := map[string]int{"1valid": 0, "1gone": -7, "1locked": -9}
+			n, ok := errno[r.URL.Query().Get("shorturl")]
+			if !ok {
+				n = 2
+			}
+			writeJSON(w, map[string]int{"errno": n})
+		case "/share/verify":
+			r.ParseForm()
+			if r.URL.Query().Get("surl") == "locked" && r.PostForm.Get("pwd") == "good" {
+				writeJSON(w, map[string]int{"errno": 0})
+			} else {
+				writeJSON(w, map[string]int{"errno": -9})
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func quarkStub(t *testing.T) *stub {
+	return newStub(t, func(w http.ResponseWriter, r *http.Request) {
+		body := readJSON(r)
+		code := 0
+		switch body["pwd_id"] {
+		case "valid":
+		case "gone":
+			code = 41011
+		case "locked":
+			if body["passcode"] == "" {
+				code = 41006
+			} else if body["passcode"] != "good" {
+				code = 41008
+			}
+		default:
+			code = 1
+		}
+		writeJSON(w, map[string]int{"code": code})
+	})
+}
+
+func aliyunStub(t *testing.T) *stub {
+	return newStub(t, func(w http.ResponseWriter, r *http.Request) {
+		switch readJSON(r)["share_id"] {
+		case "valid", "locked":
+			writeJSON(w, map[string]interface{}{"share_name": "三体", "file_count": 1})
+		case "gone":
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"code": "ShareLink.Cancelled"})
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("internal error"))
+		}
+	})
+}
+
+func pan115Stub(t *testing.T) *stub {
+	return newStub(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch query.Get("share_code") {
+		case "valid":
+			writeJSON(w, map[string]interface{}{"state": true})
+		case "gone":
+			writeJSON(w, map[string]interface{}{"state": false, "error": "分享已取消"})
+		case "locked":
+			if query.Get("receive_code") == "good" {
+				writeJSON(w, map[string]interface{}{"state": true})
+			} else {
+				writeJSON(w, map[string]interface{}{"state": false, "error": "请输入正确的访问码"})
+			}
+		default:
+			writeJSON(w, map[string]interface{}{"state": false, "error": "系统繁忙"})
+		}
+	})
+}
+
+// stubEndpoints 启动所有模拟接口
+func stubEndpoints(t *testing.T) (Endpoints, map[string]*stub) {
+	stubs := map[string]*stub{
+		"baidu":  baiduStub(t),
+		"quark":  quarkStub(t),
+		"aliyun": aliyunStub(t),
+		"115":    pan115Stub(t),
+	}
+	return Endpoints{
+		Baidu:  stubs["baidu"].URL,
+		Quark:  stubs["quark"].URL,
+		Aliyun: stubs["aliyun"].URL,
+		Pan115: stubs["115"].URL,
+	}, stubs
+}
+
+func newTestChecker(t *testing.T, endpoints Endpoints) *Checker {
+	c := New(&http.Client{Timeout: 5 * time.Second}, endpoints)
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestCheckProviders(t *testing.T) {
+	endpoints, _ := stubEndpoints(t)
+	c := newTestChecker(t, endpoints)
+
+	tests := []struct {
+		name string
+		link model.Link
+		want Status
+	}{
+		{"baidu 有效", model.Link{Type: "baidu", URL: "https://pan.baidu.com/s/1valid"}, StatusValid},
+		{"baidu 旧版链接", model.Link{Type: "baidu", URL: "https://pan.baidu.com/share/init?surl=valid"}, StatusValid},
+		{"baidu 已失效", model.Link{Type: "baidu", URL: "https://pan.baidu.com/s/1gone"}, StatusExpired},
+		{"baidu 缺少提取码", model.Link{Type: "baidu", URL: "https://pan.baidu.com/s/1locked"}, StatusPassword},
+		{"baidu 提取码错误", model.Link{Type: "baidu", URL: "https://pan.baidu.com/s/1locked", Password: "bad"}, StatusPassword},
+		{"baidu 提取码正确", model.Link{Type: "baidu", URL: "https://pan.baidu.com/s/1locked", Password: "good"}, StatusValid},
+		{"baidu 链接中的提取码", model.Link{Type: "baidu", URL: "https://pan.baidu.com/s/1locked?pwd=good"}, StatusValid},
+		{"baidu 未知结果", model.Link{Type: "baidu", URL: "https://pan.baidu.com/s/1other"}, StatusUnknown},
+
+		{"quark 有效", model.Link{Type: "quark", URL: "https://pan.quark.cn/s/valid"}, StatusValid},
+		{"quark 已失效", model.Link{Type: "quark", URL: "https://pan.quark.cn/s/gone"}, StatusExpired},
+		{"quark 缺少提取码", model.Link{Type: "quark", URL: "https://pan.quark.cn/s/locked"}, StatusPassword},
+		{"quark 提取码错误", model.Link{Type: "quark", URL: "https://pan.quark.cn/s/locked", Password: "bad"}, StatusPassword},
+		{"quark 提取码正确", model.Link{Type: "quark", URL: "https://pan.quark.cn/s/locked", Password: "good"}, StatusValid},
+		{"quark 未知结果", model.Link{Type: "quark", URL: "https://pan.quark.cn/s/other"}, StatusUnknown},
+
+		{"aliyun 有效", model.Link{Type: "aliyun", URL: "https://www.alipan.com/s/valid"}, StatusValid},
+		{"aliyun 已失效", model.Link{Type: "aliyun", URL: "https://www.aliyundrive.com/s/gone"}, StatusExpired},
+		// 阿里云盘的接口不校验提取码
+		{"aliyun 提取码错误", model.Link{Type: "aliyun", URL: "https://www.alipan.com/s/locked", Password: "bad"}, StatusValid},
+		{"aliyun 接口异常", model.Link{Type: "aliyun", URL: "https://www.alipan.com/s/other"}, StatusUnknown},
+
+		{"115 有效", model.Link{Type: "115", URL: "https://115.com/s/valid"}, StatusValid},
+		{"115 已失效", model.Link{Type: "115", URL: "https://115.com/s/gone"}, StatusExpired},
+		{"115 提取码错误", model.Link{Type: "115", URL: "https://115.com/s/locked?password=bad"}, StatusPassword},
+		{"115 提取码正确", model.Link{Type: "115", URL: "https://115.com/s/locked", Password: "good"}, StatusValid},
+		{"115 未知结果", model.Link{Type: "115", URL: "https://115.com/s/other"}, StatusUnknown},
+
+		{"不支持的类型", model.Link{Type: "magnet", URL: "magnet:?xt=urn:btih:abc"}, StatusUnknown},
+		{"无法解析的链接", model.Link{Type: "quark", URL: "not a link"}, StatusUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Check(context.Background(), tt.link); got != tt.want {
+				t.Errorf("Check(%s) = %s, want %s", tt.link.URL, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckNetworkError(t *testing.T) {
+	endpoints, stubs := stubEndpoints(t)
+	for _, s := range stubs {
+		s.Close()
+	}
+	c := newTestChecker(t, endpoints)
+
+	for _, link := range []model.Link{
+		{Type: "baidu", URL: "https://pan.baidu.com/s/1valid"},
+		{Type: "quark", URL: "https://pan.quark.cn/s/valid"},
+		{Type: "aliyun", URL: "https://www.alipan.com/s/valid"},
+		{Type: "115", URL: "https://115.com/s/valid"},
+	} {
+		if got := c.Check(context.Background(), link); got != StatusUnknown {
+			t.Errorf("%s: 网络错误时应返回 %s, got %s", link.Type, StatusUnknown, got)
+		}
+	}
+}
+
+func TestCheckCancelledContext(t *testing.T) {
+	endpoints, _ := stubEndpoints(t)
+	c := newTestChecker(t, endpoints)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := c.Check(ctx, model.Link{Type: "quark", URL: "https://pan.quark.cn/s/valid"}); got != StatusUnknown {
+		t.Errorf("ctx 已取消时应返回 %s, got %s", StatusUnknown, got)
+	}
+}
+
+func TestCheckCachesVerdicts(t *testing.T) {
+	endpoints, stubs := stubEndpoints(t)
+	c := newTestChecker(t, endpoints)
+	quark := stubs["quark"]
+
+	tests := []struct {
+		id     string
+		want   Status
+		cached bool
+	}{
+		{"valid", StatusValid, true},
+		{"gone", StatusExpired, true},
+		{"locked", StatusPassword, true},
+		{"other", StatusUnknown, false}, // 未知结果不缓存，下次重新检测
+	}
+
+	for _, tt := range tests {
+		link := model.Link{Type: "quark", URL: "https://pan.quark.cn/s/" + tt.id}
+		before := quark.hits.Load()
+		for i := 0; i < 3; i++ {
+			if got := c.Check(context.Background(), link); got != tt.want {
+				t.Fatalf("%s: got %s, want %s", tt.id, got, tt.want)
+			}
+		}
+		hits := quark.hits.Load() - before
+		if tt.cached && hits != 1 {
+			t.Errorf("%s: 结果应被缓存，接口被请求了 %d 次", tt.id, hits)
+		}
+		if !tt.cached && hits != 3 {
+			t.Errorf("%s: 结果不应被缓存，接口被请求了 %d 次", tt.id, hits)
+		}
+	}
+
+	// 提取码不同的相同链接分别检测
+	before := quark.hits.Load()
+	link := model.Link{Type: "quark", URL: "https://pan.quark.cn/s/locked", Password: "good"}
+	if got := c.Check(context.Background(), link); got != StatusValid {
+		t.Fatalf("got %s, want %s", got, StatusValid)
+	}
+	if quark.hits.Load()-before != 1 {
+		t.Error("提取码不同时不应使用缓存的结果")
+	}
+}
+
+func TestCheckTTLExpiry(t *testing.T) {
+	endpoints, stubs := stubEndpoints(t)
+	c := newTestChecker(t, endpoints)
+	c.SetTTL(50 * time.Millisecond)
+	baidu := stubs["baidu"]
+
+	valid := model.Link{Type: "baidu", URL: "https://pan.baidu.com/s/1valid"}
+	gone := model.Link{Type: "baidu", URL: "https://pan.baidu.com/s/1gone"}
+
+	c.Check(context.Background(), valid)
+	c.Check(context.Background(), gone)
+	c.Check(context.Background(), valid)
+	c.Check(context.Background(), gone)
+	if hits := baidu.hits.Load(); hits != 2 {
+		t.Fatalf("TTL 内应使用缓存，接口被请求了 %d 次", hits)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// 有效结果超过 TTL 后重新检测，已失效的结果缓存 expiredTTL
+	c.Check(context.Background(), valid)
+	c.Check(context.Background(), gone)
+	if hits := baidu.hits.Load(); hits != 3 {
+		t.Fatalf("超过 TTL 后应只重新检测有效链接，接口共被请求了 %d 次", hits)
+	}
+}
+
+func TestCheckAll(t *testing.T) {
+	endpoints, stubs := stubEndpoints(t)
+	c := newTestChecker(t, endpoints)
+
+	links := []model.Link{
+		{Type: "baidu", URL: "https://pan.baidu.com/s/1valid"},
+		{Type: "quark", URL: "https://pan.quark.cn/s/gone"},
+		{Type: "aliyun", URL: "https://www.alipan.com/s/valid"},
+		{Type: "115", URL: "https://115.com/s/locked"},
+		{Type: "quark", URL: "https://pan.quark.cn/s/gone"}, // 重复链接只检测一次
+		{Type: "magnet", URL: "magnet:?xt=urn:btih:abc"},
+	}
+	statuses := c.CheckAll(context.Background(), links)
+
+	want := map[string]Status{
+		Key(links[0]): StatusValid,
+		Key(links[1]): StatusExpired,
+		Key(links[2]): StatusValid,
+		Key(links[3]): StatusPassword,
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("CheckAll 返回 %d 个结果, want %d: %v", len(statuses), len(want), statuses)
+	}
+	for key, status := range want {
+		if statuses[key] != status {
+			t.Errorf("%s: got %s, want %s", strings.Split(key, "|")[1], statuses[key], status)
+		}
+	}
+	if hits := stubs["quark"].hits.Load(); hits != 1 {
+		t.Errorf("重复链接被检测了 %d 次", hits)
+	}
+}
@@ -0,0 +1,261 @@
+package linkcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"pansou-openwrt/internal/links"
+	"pansou-openwrt/internal/model"
+)
+
+// Endpoints 各网盘分享信息接口的地址（协议+主机）
+type Endpoints struct {
+	Baidu  string
+	Quark  string
+	Aliyun string
+	Pan115 string
+}
+
+// DefaultEndpoints 各网盘的官方接口地址
+var DefaultEndpoints = Endpoints{
+	Baidu:  "https://pan.baidu.com",
+	Quark:  "https://drive-h.quark.cn",
+	Aliyun: "https://api.aliyundrive.com",
+	Pan115: "https://webapi.115.com",
+}
+
+// maxResponseSize 接口响应最多读取的字节数，只需要其中的状态字段
+const maxResponseSize = 256 * 1024
+
+// userAgent 部分接口拒绝没有浏览器UA的请求
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+// prober 检测单个链接
+type prober func(ctx context.Context, c *Checker, link model.Link) Status
+
+var probers = map[string]prober{
+	"baidu":  probeBaidu,
+	"quark":  probeQuark,
+	"aliyun": probeAliyun,
+	"115":    probe115,
+}
+
+// probeBaidu 通过 shorturlinfo 接口检测百度网盘分享，附带提取码时再通过 verify 接口验证
+func probeBaidu(ctx context.Context, c *Checker, link model.Link) Status {
+	u, surl := shareID(link.URL)
+	if u == nil {
+		return StatusUnknown
+	}
+	// 旧版链接 /share/init?surl=xxx 等价于 /s/1xxx
+	if surl == "" && strings.TrimRight(u.Path, "/") == "/share/init" {
+		if s := u.Query().Get("surl"); s != "" {
+			surl = "1" + s
+		}
+	}
+	if len(surl) < 2 {
+		return StatusUnknown
+	}
+
+	var info struct {
+		Errno int `json:"errno"`
+	}
+	api := c.endpoints.Baidu + "/api/shorturlinfo?root=1&shorturl=" + url.QueryEscape(surl)
+	if err := c.doJSON(ctx, http.MethodGet, api, "", nil, &info); err != nil {
+		return StatusUnknown
+	}
+
+	switch info.Errno {
+	case 0:
+		return StatusValid
+	case -7, -8, -21, 105:
+		// 分享不存在、文件已删除、分享已取消、链接格式错误
+		return StatusExpired
+	case -9:
+		// 需要提取码
+	default:
+		return StatusUnknown
+	}
+
+	pwd := password(link)
+	if pwd == "" {
+		return StatusPassword
+	}
+
+	var verify struct {
+		Errno int `json:"errno"`
+	}
+	api = c.endpoints.Baidu + "/share/verify?surl=" + url.QueryEscape(surl[1:])
+	form := url.Values{"pwd": {pwd}, "vcode": {""}, "vcode_str": {""}}
+	if err := c.doJSON(ctx, http.MethodPost, api, "application/x-www-form-urlencoded",
+		strings.NewReader(form.Encode()), &verify); err != nil {
+		return StatusUnknown
+	}
+	switch verify.Errno {
+	case 0:
+		return StatusValid
+	case -9, -12:
+		return StatusPassword
+	}
+	return StatusUnknown
+}
+
+// probeQuark 通过获取分享token的接口检测夸克网盘分享
+func probeQuark(ctx context.Context, c *Checker, link model.Link) Status {
+	u, id := shareID(link.URL)
+	if u == nil || id == "" {
+		return StatusUnknown
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"pwd_id":   id,
+		"passcode": password(link),
+	})
+	var resp struct {
+		Code int `json:"code"`
+	}
+	api := c.endpoints.Quark + "/1/clouddrive/share/sharepage/token?pr=ucpro&fr=pc"
+	if err := c.doJSON(ctx, http.MethodPost, api, "application/json", bytes.NewReader(body), &resp); err != nil {
+		return StatusUnknown
+	}
+
+	switch resp.Code {
+	case 0:
+		return StatusValid
+	case 41006, 41008:
+		// 需要提取码、提取码错误
+		return StatusPassword
+	case 41004, 41010, 41011, 41012:
+		// 分享不存在、已失效、已过期、已取消
+		return StatusExpired
+	}
+	return StatusUnknown
+}
+
+// probeAliyun 通过匿名获取分享信息的接口检测阿里云盘分享
+// 该接口不校验提取码，带提取码的有效分享也返回 StatusValid
+func probeAliyun(ctx context.Context, c *Checker, link model.Link) Status {
+	u, id := shareID(link.URL)
+	if u == nil || id == "" {
+		return StatusUnknown
+	}
+
+	body, _ := json.Marshal(map[string]string{"share_id": id})
+	var resp struct {
+		Code      string `json:"code"`
+		ShareName string `json:"share_name"`
+		FileCount *int   `json:"file_count"`
+	}
+	api := c.endpoints.Aliyun + "/adrive/v3/share_link/get_share_by_anonymous?share_id=" + url.QueryEscape(id)
+	if err := c.doJSON(ctx, http.MethodPost, api, "application/json", bytes.NewReader(body), &resp); err != nil {
+		return StatusUnknown
+	}
+
+	switch {
+	case resp.Code == "" && (resp.ShareName != "" || resp.FileCount != nil):
+		return StatusValid
+	case strings.HasPrefix(resp.Code, "ShareLink.") || resp.Code == "NotFound.ShareLink":
+		// ShareLink.Cancelled、ShareLink.Expired、ShareLink.Forbidden 等
+		return StatusExpired
+	}
+	return StatusUnknown
+}
+
+// probe115 通过分享快照接口检测115网盘分享
+func probe115(ctx context.Context, c *Checker, link model.Link) Status {
+	u, code := shareID(link.URL)
+	if u == nil || code == "" {
+		return StatusUnknown
+	}
+
+	var resp struct {
+		State bool   `json:"state"`
+		Error string `json:"error"`
+	}
+	query := url.Values{
+		"share_code":   {code},
+		"receive_code": {password(link)},
+		"offset":       {"0"},
+		"limit":        {"1"},
+	}
+	api := c.endpoints.Pan115 + "/share/snap?" + query.Encode()
+	if err := c.doJSON(ctx, http.MethodGet, api, "", nil, &resp); err != nil {
+		return StatusUnknown
+	}
+
+	if resp.State {
+		return StatusValid
+	}
+	// 115的错误码较多且未公开，按错误信息判断
+	switch {
+	case strings.Contains(resp.Error, "访问码") || strings.Contains(resp.Error, "提取码"):
+		return StatusPassword
+	case containsAny(resp.Error, "取消", "过期", "不存在", "已删除", "违规"):
+		return StatusExpired
+	}
+	return StatusUnknown
+}
+
+// shareID 解析分享链接，返回URL和 /s/ 后面的分享ID，链接无法解析时URL为nil
+func shareID(raw string) (*url.URL, string) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return nil, ""
+	}
+	id, ok := strings.CutPrefix(u.Path, "/s/")
+	if !ok {
+		return u, ""
+	}
+	id, _, _ = strings.Cut(id, "/")
+	return u, id
+}
+
+// password 返回链接的提取码，未单独提供时从链接参数中读取
+func password(link model.Link) string {
+	if link.Password != "" {
+		return link.Password
+	}
+	return links.URLPassword(link.URL)
+}
+
+// doJSON 发送请求并解析JSON响应，非2xx状态码的响应体同样解析（各接口在错误时也返回JSON）
+func (c *Checker) doJSON(ctx context.Context, method, api, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, api, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("HTTP %d: %w", resp.StatusCode, err)
+	}
+	return nil
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package links
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"pansou-openwrt/internal/model"
+)
+
+var (
+	// urlRegex 文本中的链接：磁力链接、ed2k链接、带或不带协议前缀的网址
+	// 网址只匹配ASCII字符，紧跟在链接后的中文（如“提取码”）不会被当作链接的一部分
+	urlRegex = regexp.MustCompile(`(?i)(?:magnet:\?xt=urn:btih:[0-9a-z]{32,40}[\w\-.~:/?#@!$&*+,;=%]*` +
+		`|ed2k://\|file\|[^\s"'<>]+?\|/` +
+		`|(?:https?://)?(?:[a-z0-9-]+\.)+[a-z]{2,}(?::\d+)?/[\w\-.~:/?#@!$&*+,;=%]*)`)
+
+	// passwordRegex 提取码，关键词前不能是字母（避免匹配 unicode 等单词）
+	passwordRegex = regexp.MustCompile(`(?i)(?:^|[^a-z])(?:提取码|密码|访问码|pwd|passcode|code)\s*[：:=]?\s*([a-z0-9]{4,8})(?:[^a-z0-9]|$)`)
+)
+
+// passwordParams 链接中携带提取码的参数
+var passwordParams = []string{"pwd", "password", "passcode", "code"}
+
+// hostTypes 网盘域名，子域名同样匹配
+var hostTypes = map[string]string{
+	"pan.baidu.com":          "baidu",
+	"yun.baidu.com":          "baidu",
+	"aliyundrive.com":        "aliyun",
+	"alipan.com":             "aliyun",
+	"pan.quark.cn":           "quark",
+	"drive.quark.cn":         "quark",
+	"cloud.189.cn":           "tianyi",
+	"drive.uc.cn":            "uc",
+	"fast.uc.cn":             "uc",
+	"yun.139.com":            "mobile",
+	"caiyun.139.com":         "mobile",
+	"caiyun.feixin.10086.cn": "mobile",
+	"115.com":                "115",
+	"115cdn.com":             "115",
+	"anxia.com":              "115",
+	"mypikpak.com":           "pikpak",
+	"pikpak.com":             "pikpak",
+	"pan.xunlei.com":         "xunlei",
+	"123pan.com":             "123",
+	"123pan.cn":              "123",
+	"123684.com":             "123",
+	"123865.com":             "123",
+	"123912.com":             "123",
+	"ilanzou.com":            "lanzou",
+	"lanzn.com":              "lanzou",
+}
+
+// Type 识别链接的网盘类型，不是网盘分享链接时返回空字符串
+func Type(rawURL string) string {
+	link, _ := Parse(rawURL)
+	return link.Type
+}
+
+// Parse 解析单个分享链接：识别网盘类型，补全省略的 https:// 前缀，并从链接参数中读取提取码
+// 不是网盘分享链接（包括网盘首页等没有路径的链接）时返回false
+func Parse(rawURL string) (model.Link, bool) {
+	raw := strings.TrimSpace(rawURL)
+	if decoded, err := url.QueryUnescape(raw); err == nil && strings.HasPrefix(strings.ToLower(decoded), "magnet:") {
+		raw = decoded
+	}
+
+	lower := strings.ToLower(raw)
+	switch {
+	case strings.HasPrefix(lower, "magnet:?"):
+		return model.Link{Type: "magnet", URL: raw}, true
+	case strings.HasPrefix(lower, "ed2k://"):
+		return model.Link{Type: "ed2k", URL: raw}, true
+	}
+
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return model.Link{}, false
+	}
+	typ := hostType(u.Hostname())
+	if typ == "" || (strings.Trim(u.Path, "/") == "" && u.RawQuery == "") {
+		return model.Link{}, false
+	}
+
+	return model.Link{Type: typ, URL: raw, Password: queryPassword(u)}, true
+}
+
+// hostType 根据域名识别网盘类型
+func hostType(host string) string {
+	host = strings.ToLower(host)
+	for h := host; h != ""; {
+		if typ, ok := hostTypes[h]; ok {
+			return typ
+		}
+		_, parent, ok := strings.Cut(h, ".")
+		if !ok {
+			break
+		}
+		h = parent
+	}
+
+	// 蓝奏云使用大量 lanzouX.com 形式的域名
+	for _, label := range strings.Split(host, ".") {
+		if strings.HasPrefix(label, "lanzou") {
+			return "lanzou"
+		}
+	}
+	return ""
+}
+
+// URLPassword 返回链接参数中的提取码（pwd、password 等），没有时返回空字符串
+func URLPassword(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	return queryPassword(u)
+}
+
+func queryPassword(u *url.URL) string {
+	query := u.Query()
+	for _, name := range passwordParams {
+		if pwd := query.Get(name); pwd != "" {
+			return pwd
+		}
+	}
+	return ""
+}
+
+// Password 查找文本中的第一个提取码（提取码/密码/访问码/pwd 等关键词后的4-8位字母数字）
+func Password(text string) string {
+	if m := passwordRegex.FindStringSubmatch(text); len(m) > 1 {
+		return m[1]
+	}
+	return ""
+}
+
+// IsURL 判断整段文本是否只是一个链接
+func IsURL(text string) bool {
+	text = strings.TrimSpace(text)
+	return text != "" && urlRegex.FindString(text) == text
+}
+
+// needsPassword 判断该类型的链接是否可能需要提取码
+func needsPassword(typ string) bool {
+	return typ != "magnet" && typ != "ed2k"
+}
+
+// Find 找出文本中所有网盘分享链接，提取码取链接之后、下一个链接之前的文本中的第一个
+func Find(text string) []model.Link {
+	return Extract(text, nil)
+}
+
+// Extract 找出文本中的网盘分享链接，并加入 hrefs 中的链接（如超链接地址，可能不出现在文本中）
+// 文本中的链接在其后、下一个链接之前查找提取码；不在文本中的链接只在文本中没有其他链接时使用整段文本中的提取码
+// 结果按URL去重，hrefs 中的链接排在前面
+func Extract(text string, hrefs []string) []model.Link {
+	return extract(text, hrefs, true)
+}
+
+// extract 见 Extract，wholeText 为false时不在整段文本中为不在文本中的链接查找提取码
+func extract(text string, hrefs []string, wholeText bool) []model.Link {
+	inText := make(map[string]model.Link)
+	order := make([]string, 0)
+
+	matches := urlRegex.FindAllStringIndex(text, -1)
+	for i, m := range matches {
+		link, ok := Parse(trimURL(text[m[0]:m[1]]))
+		if !ok {
+			continue
+		}
+		if _, ok := inText[link.URL]; ok {
+			continue
+		}
+		if link.Password == "" && needsPassword(link.Type) {
+			end := len(text)
+			if i+1 < len(matches) {
+				end = matches[i+1][0]
+			}
+			link.Password = Password(text[m[1]:end])
+		}
+		inText[link.URL] = link
+		order = append(order, link.URL)
+	}
+
+	links := make([]model.Link, 0, len(hrefs)+len(order))
+	seen := make(map[string]bool)
+	for _, href := range hrefs {
+		link, ok := Parse(href)
+		if !ok || seen[link.URL] {
+			continue
+		}
+		if textLink, ok := inText[link.URL]; ok {
+			if link.Password == "" {
+				link.Password = textLink.Password
+			}
+		} else if wholeText && link.Password == "" && needsPassword(link.Type) && len(matches) == 0 {
+			link.Password = Password(text)
+		}
+		seen[link.URL] = true
+		links = append(links, link)
+	}
+	for _, u := range order {
+		if !seen[u] {
+			seen[u] = true
+			links = append(links, inText[u])
+		}
+	}
+	return links
+}
+
+// FromHTML 找出HTML片段中的网盘分享链接，包括超链接和正文中的纯文本链接
+// 超链接的提取码在链接文字及其后的同级文本中查找；只有一个链接时也会在整段文本中查找
+func FromHTML(sel *goquery.Selection) []model.Link {
+	text := sel.Text()
+
+	hrefs := make([]string, 0)
+	nearby := make(map[string]string) // 超链接URL -> 附近文本中的提取码
+	sel.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		link, ok := Parse(href)
+		if !ok {
+			return
+		}
+		hrefs = append(hrefs, href)
+		if _, ok := nearby[link.URL]; !ok && link.Password == "" && needsPassword(link.Type) {
+			nearby[link.URL] = Password(textAfter(a))
+		}
+	})
+
+	links := extract(text, hrefs, false)
+	for i := range links {
+		if links[i].Password == "" {
+			links[i].Password = nearby[links[i].URL]
+		}
+	}
+	if len(links) == 1 && links[0].Password == "" && needsPassword(links[0].Type) {
+		links[0].Password = Password(text)
+	}
+	return links
+}
+
+// textAfter 返回父元素文本中从超链接文字开始的部分
+func textAfter(a *goquery.Selection) string {
+	parentText := a.Parent().Text()
+	if anchorText := a.Text(); anchorText != "" {
+		if i := strings.Index(parentText, anchorText); i >= 0 {
+			return parentText[i:]
+		}
+	}
+	return parentText
+}
+
+// trimURL 去掉链接末尾的标点
+func trimURL(s string) string {
+	return strings.TrimRight(s, ".,;:!?*")
+}
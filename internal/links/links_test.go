@@ -0,0 +1,238 @@
+package links
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"pansou-openwrt/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want model.Link // Type 为空表示不是分享链接
+	}{
+		{"https://pan.baidu.com/s/1AbCdEf", model.Link{Type: "baidu", URL: "https://pan.baidu.com/s/1AbCdEf"}},
+		{"https://pan.baidu.com/s/1AbCdEf?pwd=x1y2", model.Link{Type: "baidu", URL: "https://pan.baidu.com/s/1AbCdEf?pwd=x1y2", Password: "x1y2"}},
+		{"https://pan.baidu.com/share/init?surl=AbCdEf", model.Link{Type: "baidu", URL: "https://pan.baidu.com/share/init?surl=AbCdEf"}},
+		{"https://yun.baidu.com/s/1AbCdEf", model.Link{Type: "baidu", URL: "https://yun.baidu.com/s/1AbCdEf"}},
+		{"https://www.aliyundrive.com/s/abc", model.Link{Type: "aliyun", URL: "https://www.aliyundrive.com/s/abc"}},
+		{"https://www.alipan.com/s/abc", model.Link{Type: "aliyun", URL: "https://www.alipan.com/s/abc"}},
+		{"https://alipan.com/t/abc", model.Link{Type: "aliyun", URL: "https://alipan.com/t/abc"}},
+		{"https://pan.quark.cn/s/abc123", model.Link{Type: "quark", URL: "https://pan.quark.cn/s/abc123"}},
+		{"https://drive.quark.cn/s/abc123", model.Link{Type: "quark", URL: "https://drive.quark.cn/s/abc123"}},
+		{"pan.quark.cn/s/abc123", model.Link{Type: "quark", URL: "https://pan.quark.cn/s/abc123"}},
+		{"https://PAN.QUARK.CN/s/abc123", model.Link{Type: "quark", URL: "https://PAN.QUARK.CN/s/abc123"}},
+		{"https://cloud.189.cn/t/abc", model.Link{Type: "tianyi", URL: "https://cloud.189.cn/t/abc"}},
+		{"https://h5.cloud.189.cn/share.html#/t/abc", model.Link{Type: "tianyi", URL: "https://h5.cloud.189.cn/share.html#/t/abc"}},
+		{"https://drive.uc.cn/s/abc", model.Link{Type: "uc", URL: "https://drive.uc.cn/s/abc"}},
+		{"https://yun.139.com/link/abc", model.Link{Type: "mobile", URL: "https://yun.139.com/link/abc"}},
+		{"https://caiyun.139.com/m/i?abc", model.Link{Type: "mobile", URL: "https://caiyun.139.com/m/i?abc"}},
+		{"https://115.com/s/abc?password=1a2b", model.Link{Type: "115", URL: "https://115.com/s/abc?password=1a2b", Password: "1a2b"}},
+		{"https://115cdn.com/s/abc", model.Link{Type: "115", URL: "https://115cdn.com/s/abc"}},
+		{"https://mypikpak.com/s/abc", model.Link{Type: "pikpak", URL: "https://mypikpak.com/s/abc"}},
+		{"https://pan.xunlei.com/s/abc?pwd=wxyz", model.Link{Type: "xunlei", URL: "https://pan.xunlei.com/s/abc?pwd=wxyz", Password: "wxyz"}},
+		{"https://www.123pan.com/s/abc", model.Link{Type: "123", URL: "https://www.123pan.com/s/abc"}},
+		{"https://www.123684.com/s/abc", model.Link{Type: "123", URL: "https://www.123684.com/s/abc"}},
+		{"https://wwi.lanzoup.com/abc", model.Link{Type: "lanzou", URL: "https://wwi.lanzoup.com/abc"}},
+		{"https://www.ilanzou.com/s/abc", model.Link{Type: "lanzou", URL: "https://www.ilanzou.com/s/abc"}},
+		{"magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567", model.Link{Type: "magnet", URL: "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567"}},
+		{"magnet%3A%3Fxt%3Durn%3Abtih%3A0123456789abcdef0123456789abcdef01234567", model.Link{Type: "magnet", URL: "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567"}},
+		{"ed2k://|file|movie.mkv|123|ABCDEF|/", model.Link{Type: "ed2k", URL: "ed2k://|file|movie.mkv|123|ABCDEF|/"}},
+
+		// 网盘首页、其他网站和其他协议
+		{"https://pan.baidu.com/", model.Link{}},
+		{"https://pan.quark.cn", model.Link{}},
+		{"https://www.baidu.com/s?wd=abc", model.Link{}},
+		{"https://example.com/s/abc", model.Link{}},
+		{"ftp://pan.baidu.com/s/1abc", model.Link{}},
+		{"", model.Link{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, ok := Parse(tt.raw)
+			if ok != (tt.want.Type != "") {
+				t.Fatalf("Parse(%q) ok = %v", tt.raw, ok)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.raw, got, tt.want)
+			}
+			if typ := Type(tt.raw); typ != tt.want.Type {
+				t.Errorf("Type(%q) = %q, want %q", tt.raw, typ, tt.want.Type)
+			}
+		})
+	}
+}
+
+func TestPassword(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"提取码：x1y2", "x1y2"},
+		{"提取码: x1y2", "x1y2"},
+		{"密码 abcd", "abcd"},
+		{"访问码=AB12", "AB12"},
+		{"pwd=wxyz", "wxyz"},
+		{"PWD:1234", "1234"},
+		{"passcode: 8888", "8888"},
+		{"链接 提取码：12345678 其他", "12345678"},
+		{"提取码：abc", ""},       // 少于4位
+		{"提取码：abcdefghi", ""}, // 多于8位
+		{"unicode:abcd", ""},  // code 前是字母
+		{"没有提取码", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Password(tt.text); got != tt.want {
+			t.Errorf("Password(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestURLPassword(t *testing.T) {
+	tests := map[string]string{
+		"https://pan.baidu.com/s/1abc?pwd=efgh":        "efgh",
+		"https://115.com/s/abc?password=1a2b":          "1a2b",
+		"https://pan.quark.cn/s/abc?passcode=9z9z":     "9z9z",
+		"https://example.com/?code=c0de&pwd=":          "c0de",
+		"https://pan.quark.cn/s/abc":                   "",
+		"https://pan.baidu.com/share/init?surl=AbCdEf": "",
+	}
+	for raw, want := range tests {
+		if got := URLPassword(raw); got != want {
+			t.Errorf("URLPassword(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []model.Link
+	}{
+		{
+			name: "提取码在链接之后",
+			text: "链接：https://pan.baidu.com/share/init?surl=AbCdEf 提取码：x1y2",
+			want: []model.Link{{Type: "baidu", URL: "https://pan.baidu.com/share/init?surl=AbCdEf", Password: "x1y2"}},
+		},
+		{
+			name: "紧跟中文的链接",
+			text: "夸克：pan.quark.cn/s/abc123提取码：q1w2",
+			want: []model.Link{{Type: "quark", URL: "https://pan.quark.cn/s/abc123", Password: "q1w2"}},
+		},
+		{
+			name: "每个链接使用其后的提取码",
+			text: "百度 https://pan.baidu.com/s/1aaa 提取码：bd12\n阿里 https://www.alipan.com/s/bbb\n迅雷 https://pan.xunlei.com/s/ccc 提取码：xl34",
+			want: []model.Link{
+				{Type: "baidu", URL: "https://pan.baidu.com/s/1aaa", Password: "bd12"},
+				{Type: "aliyun", URL: "https://www.alipan.com/s/bbb"},
+				{Type: "xunlei", URL: "https://pan.xunlei.com/s/ccc", Password: "xl34"},
+			},
+		},
+		{
+			name: "链接参数中的提取码优先",
+			text: "https://pan.baidu.com/s/1aaa?pwd=url1 提取码：txt2",
+			want: []model.Link{{Type: "baidu", URL: "https://pan.baidu.com/s/1aaa?pwd=url1", Password: "url1"}},
+		},
+		{
+			name: "去掉末尾标点并去重",
+			text: "https://pan.quark.cn/s/abc123. 备用：https://pan.quark.cn/s/abc123，",
+			want: []model.Link{{Type: "quark", URL: "https://pan.quark.cn/s/abc123"}},
+		},
+		{
+			name: "磁力链接不查找提取码",
+			text: "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567&dn=test 密码 abcd",
+			want: []model.Link{{Type: "magnet", URL: "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567&dn=test"}},
+		},
+		{
+			name: "忽略其他网站",
+			text: "官网 https://example.com/download 网盘 https://drive.uc.cn/s/uc1",
+			want: []model.Link{{Type: "uc", URL: "https://drive.uc.cn/s/uc1"}},
+		},
+		{
+			name: "没有链接",
+			text: "今天没有更新 提取码：abcd",
+			want: []model.Link{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Find(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Find(%q)\n got: %+v\nwant: %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractHrefs(t *testing.T) {
+	// 超链接地址不在文本中，文本中也没有其他链接时使用整段文本中的提取码
+	got := Extract("点击下载 提取码：ab12", []string{"https://pan.baidu.com/s/1aaa", "https://example.com/"})
+	want := []model.Link{{Type: "baidu", URL: "https://pan.baidu.com/s/1aaa", Password: "ab12"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// 超链接排在前面，与文本中相同的链接合并
+	got = Extract("https://pan.quark.cn/s/q1 提取码：qq11 另见 https://pan.baidu.com/s/1bbb",
+		[]string{"https://pan.baidu.com/s/1bbb", "https://pan.quark.cn/s/q1"})
+	want = []model.Link{
+		{Type: "baidu", URL: "https://pan.baidu.com/s/1bbb"},
+		{Type: "quark", URL: "https://pan.quark.cn/s/q1", Password: "qq11"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestFromHTML(t *testing.T) {
+	html := `<div class="content">
+		<p><a href="https://pan.baidu.com/s/1aaa">百度网盘</a> 提取码：bd12</p>
+		<p><a href="https://pan.quark.cn/s/q1">夸克网盘</a></p>
+		<p>阿里云盘：https://www.alipan.com/s/ali1 密码：al34</p>
+		<p><a href="https://example.com/">首页</a></p>
+	</div>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := FromHTML(doc.Find(".content"))
+	want := []model.Link{
+		{Type: "baidu", URL: "https://pan.baidu.com/s/1aaa", Password: "bd12"},
+		{Type: "quark", URL: "https://pan.quark.cn/s/q1"},
+		{Type: "aliyun", URL: "https://www.alipan.com/s/ali1", Password: "al34"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromHTML\n got: %+v\nwant: %+v", got, want)
+	}
+
+	// 只有一个链接时在整段文本中查找提取码
+	doc, _ = goquery.NewDocumentFromReader(strings.NewReader(
+		`<div class="content"><p>提取码：zz99</p><p><a href="https://pan.quark.cn/s/q2">下载</a></p></div>`))
+	got = FromHTML(doc.Find(".content"))
+	if len(got) != 1 || got[0].Password != "zz99" {
+		t.Errorf("FromHTML = %+v", got)
+	}
+}
+
+func TestIsURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://pan.quark.cn/s/abc123":   true,
+		"  pan.baidu.com/s/1abc  ":        true,
+		"https://pan.quark.cn/s/abc 三体":   false,
+		"三体 全集":                           false,
+		"":                                false,
+		"ed2k://|file|movie.mkv|123|AB|/": true,
+	}
+	for text, want := range tests {
+		if got := IsURL(text); got != want {
+			t.Errorf("IsURL(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
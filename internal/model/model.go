@@ -4,24 +4,47 @@ import "time"
 
 // SearchRequest 搜索请求
 type SearchRequest struct {
-	Keyword     string                 `json:"keyword" binding:"required"`
-	Channels    []string               `json:"channels"`
-	Plugins     []string               `json:"plugins"`
-	CloudTypes  []string               `json:"cloud_types"`
-	Concurrency int                    `json:"concurrency"`
-	ForceRefresh bool                  `json:"force_refresh"`
-	SourceType  string                 `json:"source_type"` // all, tg, plugin
-	ResultType  string                 `json:"result_type"` // all, results, merge
-	Ext         map[string]interface{} `json:"ext"`
+	Keyword       string                 `json:"keyword"`
+	Channels      []string               `json:"channels"`
+	Plugins       []string               `json:"plugins"`
+	CloudTypes    []string               `json:"cloud_types"`
+	Concurrency   int                    `json:"concurrency"`
+	ForceRefresh  bool                   `json:"force_refresh"`
+	SourceType    string                 `json:"source_type"`    // all, tg, plugin
+	ResultType    string                 `json:"result_type"`    // all, results, merge
+	ValidateLinks string                 `json:"validate_links"` // off, flag, drop，为空时使用配置中的默认值
+	Ext           map[string]interface{} `json:"ext"`
+
+	// 分页，从缓存的完整结果中截取，不会重新搜索
+	Page      int    `json:"page"`       // 页码，从1开始；page 和 page_size 都为0时不分页
+	PageSize  int    `json:"page_size"`  // 每页条数
+	TypeLimit int    `json:"type_limit"` // merged_by_type 中每种类型每页的条数，为0时与 page_size 相同
+	Cursor    string `json:"cursor"`     // 上一页响应中的 next_cursor，指定时忽略其他参数
 }
 
 // SearchResponse 搜索响应
 type SearchResponse struct {
-	Total          int             `json:"total"`
-	Results        []SearchResult  `json:"results,omitempty"`
-	MergedByType   map[string][]SearchResult `json:"merged_by_type,omitempty"`
-	SearchTime     float64         `json:"search_time"`
-	CacheHit       bool            `json:"cache_hit"`
+	Total        int                       `json:"total"`
+	Results      []SearchResult            `json:"results,omitempty"`
+	MergedByType map[string][]SearchResult `json:"merged_by_type,omitempty"`
+	SearchTime   float64                   `json:"search_time"`
+	CacheHit     bool                      `json:"cache_hit"`
+	Stale        bool                      `json:"stale,omitempty"` // 缓存已过期，结果正在后台刷新
+
+	// 分页信息，只在请求了分页或 type_limit 时返回
+	Page       int            `json:"page,omitempty"`
+	PageSize   int            `json:"page_size,omitempty"`
+	TypeTotals map[string]int `json:"type_totals,omitempty"` // merged_by_type 中每种类型截取前的结果数
+	NextCursor string         `json:"next_cursor,omitempty"` // 下一页的游标，没有下一页时为空
+}
+
+// SearchSourceEvent 单个搜索源完成事件（用于SSE流式返回）
+type SearchSourceEvent struct {
+	Source  string         `json:"source"` // plugin:xxx 或 tg
+	Count   int            `json:"count"`
+	Elapsed float64        `json:"elapsed"`
+	Error   string         `json:"error,omitempty"`
+	Results []SearchResult `json:"results"`
 }
 
 // SearchResult 搜索结果
@@ -30,18 +53,21 @@ type SearchResult struct {
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
 	Links       []Link    `json:"links"`
-	Source      string    `json:"source"`       // 来源: plugin:xxx 或 tg:channel
+	Source      string    `json:"source"`            // 来源: plugin:xxx 或 tg:channel
+	Sources     []string  `json:"sources,omitempty"` // 合并重复结果后的所有来源
 	Channel     string    `json:"channel,omitempty"`
 	PublishTime time.Time `json:"publish_time"`
 	Datetime    string    `json:"datetime"`
+	Score       float64   `json:"score"` // 相关性得分，越高越靠前
 }
 
 // Link 链接信息
 type Link struct {
-	Type     string `json:"type"`     // baidu, aliyun, quark, magnet, etc.
+	Type     string `json:"type"` // baidu, aliyun, quark, magnet, etc.
 	URL      string `json:"url"`
 	Password string `json:"password,omitempty"`
 	Size     string `json:"size,omitempty"`
+	Status   string `json:"status,omitempty"` // 链接有效性: valid, expired, password, unknown（请求了 validate_links 时）
 }
 
 // PluginSearchResult 插件搜索结果（带IsFinal标记）
@@ -58,20 +84,65 @@ type ErrorResponse struct {
 
 // HealthResponse 健康检查响应
 type HealthResponse struct {
-	Status         string   `json:"status"`
-	PluginsEnabled bool     `json:"plugins_enabled"`
-	PluginCount    int      `json:"plugin_count"`
-	PluginNames    []string `json:"plugin_names"`
-	ChannelsCount  int      `json:"channels_count"`
-	Channels       []string `json:"channels"`
-	TelegramEnabled bool    `json:"telegram_enabled"`
+	Status          string                  `json:"status"`
+	PluginsEnabled  bool                    `json:"plugins_enabled"`
+	PluginCount     int                     `json:"plugin_count"`
+	PluginNames     []string                `json:"plugin_names"`
+	ChannelsCount   int                     `json:"channels_count"`
+	Channels        []string                `json:"channels"`
+	TelegramEnabled bool                    `json:"telegram_enabled"`
+	PluginHealth    map[string]PluginHealth `json:"plugin_health"`
+	Telegram        *TelegramHealth         `json:"telegram,omitempty"`
+	Proxy           *ProxyHealth            `json:"proxy,omitempty"`
+}
+
+// ProxyHealth 出站代理状态
+type ProxyHealth struct {
+	URL         string     `json:"url"`       // 代理地址（已隐藏密码）
+	Reachable   bool       `json:"reachable"` // 代理服务器是否可连接
+	LastCheck   *time.Time `json:"last_check,omitempty"`
+	LatencyMs   int64      `json:"latency_ms"`
+	Successes   int64      `json:"successes"` // 经代理成功的请求数
+	Failures    int64      `json:"failures"`  // 经代理失败的请求数
+	LastError   string     `json:"last_error,omitempty"`
+	LastFailure *time.Time `json:"last_failure,omitempty"`
+}
+
+// TelegramHealth Telegram连通性状态
+type TelegramHealth struct {
+	Available       bool               `json:"available"`
+	LastCheck       *time.Time         `json:"last_check,omitempty"`
+	LastLatencyMs   int64              `json:"last_latency_ms"`
+	LastError       string             `json:"last_error,omitempty"`
+	IndexedMessages int                `json:"indexed_messages"` // 本地索引中的消息数
+	Bot             *TelegramBotHealth `json:"bot,omitempty"`
+}
+
+// TelegramBotHealth Bot API消息收录状态
+type TelegramBotHealth struct {
+	LastPoll  *time.Time `json:"last_poll,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// PluginHealth 插件健康状态
+type PluginHealth struct {
+	State               string     `json:"state"` // closed, open, half_open
+	Successes           int64      `json:"successes"`
+	Failures            int64      `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	AvgLatencyMs        int64      `json:"avg_latency_ms"`
+	LastLatencyMs       int64      `json:"last_latency_ms"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"`
 }
 
 // ConfigResponse 配置响应
 type ConfigResponse struct {
-	Server    interface{} `json:"server"`
-	Search    interface{} `json:"search"`
-	Telegram  interface{} `json:"telegram"`
-	Plugins   interface{} `json:"plugins"`
+	Server     interface{} `json:"server"`
+	Search     interface{} `json:"search"`
+	Telegram   interface{} `json:"telegram"`
+	Plugins    interface{} `json:"plugins"`
 	CloudTypes interface{} `json:"cloud_types"`
 }
@@ -0,0 +1,251 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"pansou-openwrt/internal/model"
+)
+
+// 熔断器状态
+const (
+	CircuitClosed   = "closed"    // 正常
+	CircuitOpen     = "open"      // 已熔断，搜索时跳过
+	CircuitHalfOpen = "half_open" // 正在后台探测
+)
+
+const (
+	failureThreshold = 3                // 连续失败多少次后熔断
+	baseOpenDuration = 1 * time.Minute  // 首次熔断时长
+	maxOpenDuration  = 15 * time.Minute // 最长熔断时长
+	probeInterval    = 15 * time.Second // 后台探测检查间隔
+	probeTimeout     = 20 * time.Second // 单次探测超时
+	probeKeyword     = "电影"             // 探测使用的关键词
+	latencyWeight    = 0.3              // 平均延迟的指数平滑系数
+)
+
+// ErrCircuitOpen 插件已熔断
+var ErrCircuitOpen = errors.New("插件已熔断，暂时跳过")
+
+// breaker 单个插件的健康状态和熔断器
+type breaker struct {
+	mu           sync.Mutex
+	state        string
+	successes    int64
+	failures     int64
+	consecutive  int
+	avgLatency   time.Duration
+	lastLatency  time.Duration
+	lastError    string
+	lastSuccess  time.Time
+	lastFailure  time.Time
+	openedAt     time.Time
+	openDuration time.Duration
+}
+
+func newBreaker() *breaker {
+	return &breaker{state: CircuitClosed}
+}
+
+// allow 判断是否允许发起搜索
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == CircuitClosed
+}
+
+// record 记录一次搜索结果，返回熔断器状态是否发生变化
+func (b *breaker) record(latency time.Duration, err error) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	prev := b.state
+	b.lastLatency = latency
+	if b.avgLatency == 0 {
+		b.avgLatency = latency
+	} else {
+		b.avgLatency = time.Duration(float64(b.avgLatency)*(1-latencyWeight) + float64(latency)*latencyWeight)
+	}
+
+	if err == nil {
+		b.successes++
+		b.consecutive = 0
+		b.lastSuccess = now
+		b.state = CircuitClosed
+		b.openDuration = 0
+		return b.state, prev != b.state
+	}
+
+	b.failures++
+	b.consecutive++
+	b.lastError = err.Error()
+	b.lastFailure = now
+
+	switch {
+	case prev == CircuitHalfOpen:
+		// 探测失败，延长熔断时间
+		b.open(now, b.openDuration*2)
+	case b.consecutive >= failureThreshold:
+		b.open(now, baseOpenDuration)
+	}
+
+	return b.state, prev != b.state
+}
+
+func (b *breaker) open(now time.Time, d time.Duration) {
+	if d < baseOpenDuration {
+		d = baseOpenDuration
+	}
+	if d > maxOpenDuration {
+		d = maxOpenDuration
+	}
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.openDuration = d
+}
+
+// tryHalfOpen 熔断时间已过时切换为半开状态，返回是否需要探测
+func (b *breaker) tryHalfOpen(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != CircuitOpen || now.Sub(b.openedAt) < b.openDuration {
+		return false
+	}
+	b.state = CircuitHalfOpen
+	return true
+}
+
+// snapshot 返回当前健康状态
+func (b *breaker) snapshot() model.PluginHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h := model.PluginHealth{
+		State:               b.state,
+		Successes:           b.successes,
+		Failures:            b.failures,
+		ConsecutiveFailures: b.consecutive,
+		AvgLatencyMs:        b.avgLatency.Milliseconds(),
+		LastLatencyMs:       b.lastLatency.Milliseconds(),
+		LastError:           b.lastError,
+	}
+	if !b.lastSuccess.IsZero() {
+		t := b.lastSuccess
+		h.LastSuccess = &t
+	}
+	if !b.lastFailure.IsZero() {
+		t := b.lastFailure
+		h.LastFailure = &t
+	}
+	if b.state != CircuitClosed {
+		t := b.openedAt.Add(b.openDuration)
+		h.RetryAt = &t
+	}
+	return h
+}
+
+// Search 通过熔断器调用插件搜索并记录健康状态
+func (m *Manager) Search(ctx context.Context, p Plugin, keyword string, ext map[string]interface{}) ([]model.SearchResult, error) {
+	b := m.breaker(p.Name())
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	start := time.Now()
+	results, err := p.Search(ctx, keyword, ext)
+
+	// 客户端主动断开不计入插件健康状态
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return nil, err
+	}
+
+	if state, changed := b.record(time.Since(start), err); changed {
+		log.Printf("[插件] %s 熔断器状态变为 %s", p.Name(), state)
+	}
+	return results, err
+}
+
+// Health 获取单个插件的健康状态
+func (m *Manager) Health(name string) model.PluginHealth {
+	return m.breaker(name).snapshot()
+}
+
+// HealthAll 获取所有插件的健康状态
+func (m *Manager) HealthAll() map[string]model.PluginHealth {
+	plugins := m.GetPlugins()
+	health := make(map[string]model.PluginHealth, len(plugins))
+	for _, p := range plugins {
+		health[p.Name()] = m.Health(p.Name())
+	}
+	return health
+}
+
+// breaker 获取插件对应的熔断器
+func (m *Manager) breaker(name string) *breaker {
+	m.breakersMu.Lock()
+	defer m.breakersMu.Unlock()
+
+	b, ok := m.breakers[name]
+	if !ok {
+		b = newBreaker()
+		m.breakers[name] = b
+	}
+	return b
+}
+
+// probeLoop 后台定期探测已熔断的插件
+func (m *Manager) probeLoop() {
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			for _, p := range m.probeCandidates(now) {
+				go m.probe(p)
+			}
+		}
+	}
+}
+
+// probeCandidates 将熔断时间已到的插件切换为半开状态并返回
+// 配置中停用的插件不会被搜索，也不探测，保持熔断直到重新启用后的下一次检查
+func (m *Manager) probeCandidates(now time.Time) []Plugin {
+	plugins := make([]Plugin, 0)
+	for _, p := range m.GetEnabledPlugins() {
+		if m.breaker(p.Name()).tryHalfOpen(now) {
+			plugins = append(plugins, p)
+		}
+	}
+	return plugins
+}
+
+// probe 半开状态下发起一次探测搜索
+func (m *Manager) probe(p Plugin) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := p.Search(ctx, probeKeyword, nil)
+	state, _ := m.breaker(p.Name()).record(time.Since(start), err)
+
+	if err != nil {
+		log.Printf("[插件] %s 探测失败: %v，保持熔断", p.Name(), err)
+	} else {
+		log.Printf("[插件] %s 探测成功，熔断器状态变为 %s", p.Name(), state)
+	}
+}
+
+// Close 停止后台探测
+func (m *Manager) Close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+	})
+}
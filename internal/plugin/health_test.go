@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"pansou-openwrt/internal/config"
+	"pansou-openwrt/internal/model"
+)
+
+// fakePlugin 返回固定错误的测试插件
+type fakePlugin struct {
+	name string
+	err  error
+}
+
+func (p *fakePlugin) Name() string        { return p.name }
+func (p *fakePlugin) DisplayName() string { return p.name }
+func (p *fakePlugin) Description() string { return "" }
+func (p *fakePlugin) Priority() int       { return 1 }
+func (p *fakePlugin) Info() Info          { return Info{Name: p.name, Enabled: true} }
+
+func (p *fakePlugin) Search(ctx context.Context, keyword string, ext map[string]interface{}) ([]model.SearchResult, error) {
+	return nil, p.err
+}
+
+func newTestManager(t *testing.T, cfg *config.Config, plugins ...Plugin) *Manager {
+	t.Helper()
+
+	m := NewManager(cfg, nil)
+	t.Cleanup(m.Close)
+	for _, p := range plugins {
+		m.Register(p)
+	}
+	return m
+}
+
+// trip 连续失败直到熔断
+func trip(t *testing.T, m *Manager, p Plugin) {
+	t.Helper()
+
+	for i := 0; i < failureThreshold; i++ {
+		m.Search(context.Background(), p, "测试", nil)
+	}
+	if _, err := m.Search(context.Background(), p, "测试", nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("连续失败后应熔断，error = %v", err)
+	}
+}
+
+func TestProbeCandidatesSkipsDisabledPlugins(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Plugins.Enabled = true
+	cfg.Plugins.List = map[string]config.PluginSettings{"off": {Enabled: false}}
+
+	on := &fakePlugin{name: "on", err: errors.New("连接失败")}
+	off := &fakePlugin{name: "off", err: errors.New("连接失败")}
+	m := newTestManager(t, cfg, on, off)
+	trip(t, m, on)
+	trip(t, m, off)
+
+	later := time.Now().Add(maxOpenDuration)
+	got := m.probeCandidates(later)
+	if len(got) != 1 || got[0].Name() != "on" {
+		t.Fatalf("探测的插件 = %v, want [on]", got)
+	}
+	if state := m.Health("on").State; state != CircuitHalfOpen {
+		t.Errorf("on 状态 = %s, want %s", state, CircuitHalfOpen)
+	}
+	if state := m.Health("off").State; state != CircuitOpen {
+		t.Errorf("停用的插件状态 = %s，应保持熔断", state)
+	}
+
+	// 全局停用插件时都不探测
+	disabled := *cfg
+	disabled.Plugins.Enabled = false
+	m.Apply(&disabled)
+	if got := m.probeCandidates(later.Add(maxOpenDuration)); len(got) != 0 {
+		t.Errorf("全局停用时探测了 %v", got)
+	}
+}
@@ -1,12 +1,16 @@
 package plugin
 
 import (
-	"net/http"
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"pansou-openwrt/internal/config"
+	"pansou-openwrt/internal/httpclient"
 	"pansou-openwrt/internal/model"
-	"pansou-openwrt/internal/plugin/plugins"
 )
 
 // Plugin 插件接口
@@ -15,95 +19,167 @@ type Plugin interface {
 	DisplayName() string
 	Description() string
 	Priority() int
-	Search(keyword string, ext map[string]interface{}) ([]model.SearchResult, error)
+	Info() Info
+	// Search 执行搜索，ctx 取消或超时时插件应立即停止所有未完成的请求
+	Search(ctx context.Context, keyword string, ext map[string]interface{}) ([]model.SearchResult, error)
 }
 
 // Manager 插件管理器
 type Manager struct {
-	config  *config.Config
-	plugins map[string]Plugin
-	client  *http.Client
+	config    atomic.Pointer[config.Config]
+	plugins   map[string]Plugin
+	pluginsMu sync.RWMutex
+	clients   *httpclient.Factory
+
+	breakers   map[string]*breaker
+	breakersMu sync.Mutex
+	stop       chan struct{}
+	closeOnce  sync.Once
 }
 
-// NewManager 创建插件管理器
-func NewManager(cfg *config.Config) *Manager {
+// NewManager 创建插件管理器，插件的HTTP客户端由 clients 按各插件的代理模式创建
+func NewManager(cfg *config.Config, clients *httpclient.Factory) *Manager {
 	m := &Manager{
-		config:  cfg,
-		plugins: make(map[string]Plugin),
-		client: &http.Client{
-			Timeout: time.Duration(cfg.Search.Timeout) * time.Second,
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
-		},
+		plugins:  make(map[string]Plugin),
+		clients:  clients,
+		breakers: make(map[string]*breaker),
+		stop:     make(chan struct{}),
 	}
+	m.config.Store(cfg)
 
 	// 注册所有插件
 	m.registerPlugins()
 
+	// 后台探测已熔断的插件
+	go m.probeLoop()
+
 	return m
 }
 
-// registerPlugins 注册所有插件（不需要登录的插件）
+// registerPlugins 实例化所有通过 Register 注册的插件
 func (m *Manager) registerPlugins() {
-	// 注册已实现的插件
-	m.Register(plugins.NewMiaosoPlugin(m.client))
-	m.Register(plugins.NewXysPlugin(m.client))
-	m.Register(plugins.NewJutoushePlugin(m.client))
-	
-	// TODO: 继续添加其他插件
-	// m.Register(plugins.NewAlupanPlugin(m.client))
-	// m.Register(plugins.NewMikuclubPlugin(m.client))
-	// m.Register(plugins.NewYpfxwPlugin(m.client))
-	// m.Register(plugins.NewKkmaoPlugin(m.client))
-	// m.Register(plugins.NewClxiongPlugin(m.client))
-	// m.Register(plugins.NewAshPlugin(m.client))
-	// m.Register(plugins.NewQingyingPlugin(m.client))
-	// m.Register(plugins.NewMeitizyPlugin(m.client))
-	// m.Register(plugins.NewWujiPlugin(m.client))
-	// m.Register(plugins.NewDyyjPlugin(m.client))
-	// m.Register(plugins.NewLabiPlugin(m.client))
-	// m.Register(plugins.NewZxzjPlugin(m.client))
-	// m.Register(plugins.NewDdysPlugin(m.client))
-	// m.Register(plugins.NewLou1Plugin(m.client))
-	// m.Register(plugins.NewPanyqPlugin(m.client))
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	timeout := time.Duration(m.cfg().Search.Timeout) * time.Second
+	for name, r := range registry {
+		m.Register(r.factory(m.clients.Client(m.ProxyMode(name), timeout)))
+	}
+}
+
+// Apply 应用新配置：插件开关和优先级立即生效，
+// 超时时间或代理模式变化时重新创建插件实例（熔断状态保留）
+func (m *Manager) Apply(cfg *config.Config) {
+	old := m.config.Swap(cfg)
+	if old.Search.Timeout == cfg.Search.Timeout && !proxyModesChanged(old, cfg) {
+		return
+	}
+
+	m.registerPlugins()
+	log.Printf("[插件] 超时时间或代理模式已变化，已重新创建插件实例")
+}
+
+// proxyModesChanged 判断是否有插件的代理模式发生变化
+func proxyModesChanged(old, cfg *config.Config) bool {
+	if old.Proxy.Mode != cfg.Proxy.Mode {
+		return true
+	}
+	for name, settings := range cfg.Plugins.List {
+		if old.Plugins.List[name].Proxy != settings.Proxy {
+			return true
+		}
+	}
+	for name, settings := range old.Plugins.List {
+		if cfg.Plugins.List[name].Proxy != settings.Proxy {
+			return true
+		}
+	}
+	return false
 }
 
-// Register 注册插件
+// cfg 返回当前配置
+func (m *Manager) cfg() *config.Config {
+	return m.config.Load()
+}
+
+// ProxyMode 返回插件的代理模式，配置中的值覆盖全局 proxy.mode
+func (m *Manager) ProxyMode(name string) string {
+	cfg := m.cfg()
+	if settings, ok := cfg.Plugins.List[name]; ok && settings.Proxy != "" {
+		return settings.Proxy
+	}
+	return cfg.Proxy.Mode
+}
+
+// Register 向管理器添加插件实例，同名插件会被替换
 func (m *Manager) Register(p Plugin) {
+	m.pluginsMu.Lock()
+	defer m.pluginsMu.Unlock()
 	m.plugins[p.Name()] = p
 }
 
 // GetPlugin 获取指定插件
 func (m *Manager) GetPlugin(name string) (Plugin, bool) {
+	m.pluginsMu.RLock()
+	defer m.pluginsMu.RUnlock()
 	p, ok := m.plugins[name]
 	return p, ok
 }
 
-// GetPlugins 获取所有插件
+// GetPlugins 获取所有插件（按名称排序）
 func (m *Manager) GetPlugins() []Plugin {
+	m.pluginsMu.RLock()
 	plugins := make([]Plugin, 0, len(m.plugins))
 	for _, p := range m.plugins {
 		plugins = append(plugins, p)
 	}
+	m.pluginsMu.RUnlock()
+
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name() < plugins[j].Name()
+	})
 	return plugins
 }
 
-// GetEnabledPlugins 获取启用的插件
+// IsEnabled 返回插件是否启用，配置中未列出的插件使用注册时的默认值
+func (m *Manager) IsEnabled(p Plugin) bool {
+	if settings, ok := m.cfg().Plugins.List[p.Name()]; ok {
+		return settings.Enabled
+	}
+	return p.Info().Enabled
+}
+
+// EffectivePriority 返回插件的实际优先级（1最高），配置中的值覆盖插件内置默认值
+func (m *Manager) EffectivePriority(p Plugin) int {
+	if settings, ok := m.cfg().Plugins.List[p.Name()]; ok && settings.Priority > 0 {
+		return settings.Priority
+	}
+	return p.Priority()
+}
+
+// SortByPriority 按优先级排序插件，优先级相同时按名称排序
+func (m *Manager) SortByPriority(plugins []Plugin) {
+	sort.SliceStable(plugins, func(i, j int) bool {
+		pi, pj := m.EffectivePriority(plugins[i]), m.EffectivePriority(plugins[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return plugins[i].Name() < plugins[j].Name()
+	})
+}
+
+// GetEnabledPlugins 获取启用的插件（按优先级排序）
 func (m *Manager) GetEnabledPlugins() []Plugin {
-	if !m.config.Plugins.Enabled {
+	if !m.cfg().Plugins.Enabled {
 		return []Plugin{}
 	}
 
 	plugins := make([]Plugin, 0)
-	for name, settings := range m.config.Plugins.List {
-		if settings.Enabled {
-			if p, ok := m.plugins[name]; ok {
-				plugins = append(plugins, p)
-			}
+	for _, p := range m.GetPlugins() {
+		if m.IsEnabled(p) {
+			plugins = append(plugins, p)
 		}
 	}
+	m.SortByPriority(plugins)
 	return plugins
 }
@@ -6,46 +6,59 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"pan-sou/internal/plugin"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"pansou-openwrt/internal/links"
+	"pansou-openwrt/internal/model"
+	"pansou-openwrt/internal/plugin"
 )
 
 const (
-	clxiongBaseURL   = "https://www.cilixiong.org"
-	clxiongSearchURL = "https://www.cilixiong.org/e/search/index.php"
-	clxiongUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
-	clxiongMaxRetry  = 3
+	clxiongBaseURL    = "https://www.cilixiong.org"
+	clxiongSearchURL  = "https://www.cilixiong.org/e/search/index.php"
+	clxiongMaxRetry   = 3
 	clxiongRetryDelay = 2 * time.Second
 )
 
+var clxiongSearchIDRegex = regexp.MustCompile(`searchid=(\d+)`)
+
 // ClxiongPlugin 磁力熊插件
-type ClxiongPlugin struct{}
+type ClxiongPlugin struct {
+	plugin.Base
+	client *http.Client
+}
 
-func init() {
-	plugin.Register("clxiong", &ClxiongPlugin{})
+var clxiongInfo = plugin.Info{
+	Name:        "clxiong",
+	DisplayName: "磁力熊",
+	Description: "磁力熊 - 磁力链接搜索引擎",
+	Priority:    2,
+	Enabled:     true,
+	CloudTypes:  []string{"magnet"},
 }
 
-func (p *ClxiongPlugin) Name() string {
-	return "磁力熊"
+func init() {
+	plugin.Register(clxiongInfo, func(client *http.Client) plugin.Plugin {
+		return NewClxiongPlugin(client)
+	})
 }
 
-func (p *ClxiongPlugin) Description() string {
-	return "磁力熊 - 磁力链接搜索引擎"
+func NewClxiongPlugin(client *http.Client) *ClxiongPlugin {
+	return &ClxiongPlugin{Base: plugin.NewBase(clxiongInfo), client: client}
 }
 
-func (p *ClxiongPlugin) Search(ctx context.Context, keyword string) ([]plugin.SearchResult, error) {
+func (p *ClxiongPlugin) Search(ctx context.Context, keyword string, ext map[string]interface{}) ([]model.SearchResult, error) {
 	// 第一步：POST搜索获取searchid
-	searchID, err := p.getSearchID(keyword)
+	searchID, err := p.getSearchID(ctx, keyword)
 	if err != nil {
 		return nil, fmt.Errorf("获取searchid失败: %w", err)
 	}
 
 	// 第二步：GET搜索结果
-	results, err := p.getSearchResults(searchID, keyword)
+	results, err := p.getSearchResults(ctx, searchID, keyword)
 	if err != nil {
 		return nil, fmt.Errorf("获取搜索结果失败: %w", err)
 	}
@@ -54,12 +67,11 @@ func (p *ClxiongPlugin) Search(ctx context.Context, keyword string) ([]plugin.Se
 }
 
 // getSearchID 第一步：POST搜索获取searchid
-func (p *ClxiongPlugin) getSearchID(keyword string) (string, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse // 不自动跟随重定向
-		},
+func (p *ClxiongPlugin) getSearchID(ctx context.Context, keyword string) (string, error) {
+	// 复用共享连接池，但不自动跟随重定向
+	client := *p.client
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
 	}
 
 	// 准备POST数据
@@ -68,81 +80,67 @@ func (p *ClxiongPlugin) getSearchID(keyword string) (string, error) {
 	formData.Set("show", "title")     // 搜索字段
 	formData.Set("tempid", "1")       // 模板ID
 	formData.Set("keyboard", keyword) // 搜索关键词
-
-	req, err := http.NewRequest("POST", clxiongSearchURL, strings.NewReader(formData.Encode()))
-	if err != nil {
-		return "", err
-	}
-
-	req.Header.Set("User-Agent", clxiongUserAgent)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Referer", clxiongBaseURL+"/")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
+	body := formData.Encode()
 
 	var resp *http.Response
 	var lastErr error
 
 	// 重试机制
 	for i := 0; i < clxiongMaxRetry; i++ {
+		if i > 0 && !sleepContext(ctx, clxiongRetryDelay) {
+			return "", ctx.Err()
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", clxiongSearchURL, strings.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+
+		req.Header.Set("User-Agent", defaultUserAgent)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Referer", clxiongBaseURL+"/")
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
+
 		resp, lastErr = client.Do(req)
-		if lastErr == nil && (resp.StatusCode == 302 || resp.StatusCode == 301) {
+		if lastErr == nil && (resp.StatusCode == http.StatusFound || resp.StatusCode == http.StatusMovedPermanently) {
 			break
 		}
 		if resp != nil {
 			resp.Body.Close()
-		}
-		if i < clxiongMaxRetry-1 {
-			time.Sleep(clxiongRetryDelay)
+			lastErr = fmt.Errorf("期望302重定向，但得到状态码: %d", resp.StatusCode)
+			resp = nil
 		}
 	}
 
-	if lastErr != nil {
+	if resp == nil {
 		return "", lastErr
 	}
 	defer resp.Body.Close()
 
-	// 检查重定向响应
-	if resp.StatusCode != 302 && resp.StatusCode != 301 {
-		return "", fmt.Errorf("期望302重定向，但得到状态码: %d", resp.StatusCode)
-	}
-
 	// 从Location头部提取searchid
 	location := resp.Header.Get("Location")
 	if location == "" {
 		return "", fmt.Errorf("重定向响应中没有Location头部")
 	}
 
-	// 解析searchid
-	searchID := p.extractSearchIDFromLocation(location)
-	if searchID == "" {
+	matches := clxiongSearchIDRegex.FindStringSubmatch(location)
+	if len(matches) < 2 {
 		return "", fmt.Errorf("无法从Location中提取searchid: %s", location)
 	}
 
-	return searchID, nil
-}
-
-// extractSearchIDFromLocation 从Location头部提取searchid
-func (p *ClxiongPlugin) extractSearchIDFromLocation(location string) string {
-	re := regexp.MustCompile(`searchid=(\d+)`)
-	matches := re.FindStringSubmatch(location)
-	if len(matches) > 1 {
-		return matches[1]
-	}
-	return ""
+	return matches[1], nil
 }
 
 // getSearchResults 第二步：GET搜索结果
-func (p *ClxiongPlugin) getSearchResults(searchID, keyword string) ([]plugin.SearchResult, error) {
+func (p *ClxiongPlugin) getSearchResults(ctx context.Context, searchID, keyword string) ([]model.SearchResult, error) {
 	resultURL := fmt.Sprintf("%s/e/search/result/?searchid=%s", clxiongBaseURL, searchID)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	req, err := http.NewRequest("GET", resultURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", resultURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", clxiongUserAgent)
+	req.Header.Set("User-Agent", defaultUserAgent)
 	req.Header.Set("Referer", clxiongBaseURL+"/")
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 
@@ -151,27 +149,26 @@ func (p *ClxiongPlugin) getSearchResults(searchID, keyword string) ([]plugin.Sea
 
 	// 重试机制
 	for i := 0; i < clxiongMaxRetry; i++ {
-		resp, lastErr = client.Do(req)
-		if lastErr == nil && resp.StatusCode == 200 {
+		if i > 0 && !sleepContext(ctx, clxiongRetryDelay) {
+			return nil, ctx.Err()
+		}
+
+		resp, lastErr = p.client.Do(req.Clone(ctx))
+		if lastErr == nil && resp.StatusCode == http.StatusOK {
 			break
 		}
 		if resp != nil {
 			resp.Body.Close()
-		}
-		if i < clxiongMaxRetry-1 {
-			time.Sleep(clxiongRetryDelay)
+			lastErr = fmt.Errorf("搜索结果请求失败，状态码: %d", resp.StatusCode)
+			resp = nil
 		}
 	}
 
-	if lastErr != nil {
+	if resp == nil {
 		return nil, lastErr
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("搜索结果请求失败，状态码: %d", resp.StatusCode)
-	}
-
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -181,42 +178,28 @@ func (p *ClxiongPlugin) getSearchResults(searchID, keyword string) ([]plugin.Sea
 }
 
 // parseSearchResults 解析搜索结果页面
-func (p *ClxiongPlugin) parseSearchResults(htmlData, keyword string) ([]plugin.SearchResult, error) {
+func (p *ClxiongPlugin) parseSearchResults(htmlData, keyword string) ([]model.SearchResult, error) {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlData))
 	if err != nil {
 		return nil, fmt.Errorf("HTML解析失败: %w", err)
 	}
 
-	var results []plugin.SearchResult
+	results := make([]model.SearchResult, 0)
 
 	// 查找搜索结果项
 	doc.Find(".list-group-item").Each(func(i int, s *goquery.Selection) {
 		// 提取标题
 		titleEl := s.Find("h5.card-title a, .title a")
 		title := strings.TrimSpace(titleEl.Text())
-		detailURL, _ := titleEl.Attr("href")
-
 		if title == "" {
 			return
 		}
 
-		// 确保URL是绝对路径
+		detailURL, _ := titleEl.Attr("href")
 		if strings.HasPrefix(detailURL, "/") {
 			detailURL = clxiongBaseURL + detailURL
 		}
 
-		// 提取磁力链接（如果页面直接显示）
-		magnetLink := ""
-		s.Find("a[href^='magnet:']").Each(func(j int, link *goquery.Selection) {
-			if href, exists := link.Attr("href"); exists {
-				magnetLink = href
-			}
-		})
-
-		// 提取时间
-		timeStr := strings.TrimSpace(s.Find(".text-muted, .time").Text())
-		publishTime := p.parseTime(timeStr)
-
 		// 提取大小
 		sizeStr := ""
 		s.Find(".badge, .size").Each(func(j int, badge *goquery.Selection) {
@@ -226,57 +209,32 @@ func (p *ClxiongPlugin) parseSearchResults(htmlData, keyword string) ([]plugin.S
 			}
 		})
 
-		results = append(results, plugin.SearchResult{
-			Title:     title,
-			Url:       magnetLink, // 磁力链接
-			Password:  "",
-			CloudType: "magnet", // 磁力链接类型
-			Size:      sizeStr,
-			CreatedAt: publishTime,
+		// 提取页面直接显示的磁力链接
+		magnets := make([]model.Link, 0)
+		s.Find("a[href^='magnet:']").Each(func(j int, a *goquery.Selection) {
+			href, _ := a.Attr("href")
+			if link, ok := links.Parse(href); ok {
+				link.Size = sizeStr
+				magnets = append(magnets, link)
+			}
 		})
-	})
-
-	// 关键词过滤
-	return p.filterByKeyword(results, keyword), nil
-}
-
-// parseTime 解析时间字符串
-func (p *ClxiongPlugin) parseTime(timeStr string) string {
-	if timeStr == "" {
-		return time.Now().Format("2006-01-02 15:04:05")
-	}
-
-	formats := []string{
-		"2006-01-02",
-		"2006/01/02",
-		"2006-01-02 15:04:05",
-		"2006年01月02日",
-	}
 
-	for _, format := range formats {
-		if t, err := time.Parse(format, timeStr); err == nil {
-			return t.Format("2006-01-02 15:04:05")
+		if len(magnets) == 0 {
+			return
 		}
-	}
-
-	return time.Now().Format("2006-01-02 15:04:05")
-}
 
-// filterByKeyword 过滤搜索结果
-func (p *ClxiongPlugin) filterByKeyword(results []plugin.SearchResult, keyword string) []plugin.SearchResult {
-	if keyword == "" {
-		return results
-	}
-
-	keyword = strings.ToLower(keyword)
-	var filtered []plugin.SearchResult
+		publishTime := parseTime(s.Find(".text-muted, .time").First().Text())
 
-	for _, result := range results {
-		title := strings.ToLower(result.Title)
-		if strings.Contains(title, keyword) {
-			filtered = append(filtered, result)
-		}
-	}
+		results = append(results, model.SearchResult{
+			UniqueID:    "clxiong:" + detailURL,
+			Title:       title,
+			Links:       magnets,
+			Source:      "plugin:clxiong",
+			PublishTime: publishTime,
+			Datetime:    formatTime(publishTime),
+		})
+	})
 
-	return filtered
+	// 关键词过滤
+	return filterByKeyword(results, keyword), nil
 }
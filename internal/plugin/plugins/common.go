@@ -0,0 +1,285 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"pansou-openwrt/internal/links"
+	"pansou-openwrt/internal/model"
+)
+
+const (
+	detailTimeout     = 10 * time.Second
+	detailConcurrency = 5
+	defaultUserAgent  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	timeLayout        = "2006-01-02 15:04:05"
+)
+
+// parseTime 解析站点返回的时间字符串，无法识别时返回零值
+func parseTime(timeStr string) time.Time {
+	timeStr = strings.TrimSpace(timeStr)
+	if timeStr == "" {
+		return time.Time{}
+	}
+
+	formats := []string{
+		"2006-01-02 15:04:05",
+		"2006-01-02",
+		"2006/01/02",
+		"2006年01月02日",
+		time.RFC3339,
+	}
+
+	for _, format := range formats {
+		if t, err := time.ParseInLocation(format, timeStr, time.Local); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// formatTime 格式化发布时间，零值返回空字符串
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(timeLayout)
+}
+
+// filterByKeyword 只保留标题包含关键词的结果
+func filterByKeyword(results []model.SearchResult, keyword string) []model.SearchResult {
+	if keyword == "" {
+		return results
+	}
+
+	keyword = strings.ToLower(keyword)
+	filtered := make([]model.SearchResult, 0, len(results))
+
+	for _, result := range results {
+		if strings.Contains(strings.ToLower(result.Title), keyword) {
+			filtered = append(filtered, result)
+		}
+	}
+
+	return filtered
+}
+
+// filterDetailItems 只保留标题包含关键词的列表项
+func filterDetailItems(items []detailItem, keyword string) []detailItem {
+	if keyword == "" {
+		return items
+	}
+
+	keyword = strings.ToLower(keyword)
+	filtered := make([]detailItem, 0, len(items))
+
+	for _, item := range items {
+		if strings.Contains(strings.ToLower(item.Title), keyword) {
+			filtered = append(filtered, item)
+		}
+	}
+
+	return filtered
+}
+
+// sleepContext 等待重试间隔，ctx 结束时返回false
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// doRequestWithRetry 带重试的HTTP请求，状态码不是200时同样重试
+func doRequestWithRetry(client *http.Client, req *http.Request, maxRetry int) (*http.Response, error) {
+	var lastErr error
+
+	for i := 0; i < maxRetry; i++ {
+		if i > 0 && !sleepContext(req.Context(), time.Duration(i)*time.Second) {
+			return nil, req.Context().Err()
+		}
+
+		resp, err := client.Do(req.Clone(req.Context()))
+		if err == nil && resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("请求返回状态码: %d", resp.StatusCode)
+		} else {
+			lastErr = err
+		}
+	}
+
+	return nil, fmt.Errorf("重试%d次后失败: %w", maxRetry, lastErr)
+}
+
+// resolveURL 按 base 解析站点返回的链接，相对路径转为绝对地址，无法解析时原样返回
+func resolveURL(base *url.URL, href string) string {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return href
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// listPage 描述搜索结果为列表页、网盘链接在详情页中的站点
+type listPage struct {
+	Item        string // 列表项
+	Title       string // 列表项中的标题链接
+	Description string // 列表项中的简介，为空表示没有
+	Time        string // 列表项中的发布时间
+	MaxRetry    int    // 列表页请求的重试次数
+}
+
+// scrapeListPage 请求搜索列表页，按标题过滤列表项后抓取详情页中的网盘链接
+// 详情页地址按列表页的最终地址解析，请求头中的 Referer 同时用于详情页请求
+func scrapeListPage(ctx context.Context, client *http.Client, req *http.Request, page listPage, keyword, source string) ([]model.SearchResult, error) {
+	resp, err := doRequestWithRetry(client, req, page.MaxRetry)
+	if err != nil {
+		return nil, fmt.Errorf("搜索请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("解析搜索页面失败: %w", err)
+	}
+
+	base := req.URL
+	if resp.Request != nil {
+		base = resp.Request.URL
+	}
+
+	items := make([]detailItem, 0)
+	doc.Find(page.Item).Each(func(i int, s *goquery.Selection) {
+		titleEl := s.Find(page.Title)
+		title := strings.TrimSpace(titleEl.Text())
+		href, exists := titleEl.Attr("href")
+		if !exists || title == "" {
+			return
+		}
+
+		item := detailItem{
+			Title:       title,
+			URL:         resolveURL(base, href),
+			PublishTime: parseTime(s.Find(page.Time).Text()),
+		}
+		if page.Description != "" {
+			item.Description = strings.TrimSpace(s.Find(page.Description).Text())
+		}
+		items = append(items, item)
+	})
+
+	// 先按标题过滤，避免抓取无关的详情页
+	items = filterDetailItems(items, keyword)
+
+	return resolveDetails(ctx, client, items, req.Header.Get("Referer"), source), nil
+}
+
+// fetchDetailLinks 抓取详情页并提取其中所有网盘链接（超链接和正文中的纯文本链接）
+func fetchDetailLinks(ctx context.Context, client *http.Client, detailURL, referer string) ([]model.Link, error) {
+	ctx, cancel := context.WithTimeout(ctx, detailTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", detailURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", defaultUserAgent)
+	if referer != "" {
+		req.Header.Set("Referer", referer)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP状态码: %d", resp.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return links.FromHTML(doc.Find("body")), nil
+}
+
+// detailItem 搜索列表页中的一项，网盘链接需要进入详情页获取
+type detailItem struct {
+	Title       string
+	URL         string
+	Description string
+	PublishTime time.Time
+}
+
+// resolveDetails 并发抓取详情页，返回包含网盘链接的结果（保持列表顺序）
+func resolveDetails(ctx context.Context, client *http.Client, items []detailItem, referer, source string) []model.SearchResult {
+	linksList := make([][]model.Link, len(items))
+	sem := make(chan struct{}, detailConcurrency)
+	var wg sync.WaitGroup
+
+	for i, item := range items {
+		if !acquire(ctx, sem) {
+			break
+		}
+		wg.Add(1)
+
+		go func(i int, detailURL string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if found, err := fetchDetailLinks(ctx, client, detailURL, referer); err == nil {
+				linksList[i] = found
+			}
+		}(i, item.URL)
+	}
+	wg.Wait()
+
+	results := make([]model.SearchResult, 0, len(items))
+	for i, item := range items {
+		if len(linksList[i]) == 0 {
+			continue
+		}
+
+		results = append(results, model.SearchResult{
+			UniqueID:    source + ":" + item.URL,
+			Title:       item.Title,
+			Description: item.Description,
+			Links:       linksList[i],
+			Source:      "plugin:" + source,
+			PublishTime: item.PublishTime,
+			Datetime:    formatTime(item.PublishTime),
+		})
+	}
+
+	return results
+}
+
+// acquire 获取并发信号量，ctx 结束时返回false
+func acquire(ctx context.Context, sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
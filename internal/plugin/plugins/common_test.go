@@ -0,0 +1,48 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScrapeListPageResolvesRelativeURLs(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<article class="post"><h2 class="entry-title"><a href="/post/1.html">三体 全集</a></h2></article>
+<article class="post"><h2 class="entry-title"><a href="2.html">三体 广播剧</a></h2></article>
+<article class="post"><h2 class="entry-title"><a href="/post/3.html">流浪地球</a></h2></article>`)
+	})
+	mux.HandleFunc("/post/1.html", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<a href="https://pan.quark.cn/s/abc123">下载</a>`)
+	})
+	mux.HandleFunc("/search/2.html", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `https://pan.baidu.com/s/1xyz 提取码: abcd`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL+"/search/?q=三体", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := scrapeListPage(context.Background(), srv.Client(), req, listPage{
+		Item:     "article.post",
+		Title:    "h2.entry-title a",
+		MaxRetry: 1,
+	}, "三体", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("返回 %d 条结果, want 2: %+v", len(results), results)
+	}
+	for i, want := range []string{srv.URL + "/post/1.html", srv.URL + "/search/2.html"} {
+		if results[i].UniqueID != "test:"+want {
+			t.Errorf("第 %d 条 UniqueID = %q, want %q", i, results[i].UniqueID, "test:"+want)
+		}
+	}
+}
@@ -5,12 +5,12 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strings"
-	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"pansou-openwrt/internal/links"
 	"pansou-openwrt/internal/model"
+	"pansou-openwrt/internal/plugin"
 )
 
 const (
@@ -18,23 +18,31 @@ const (
 )
 
 type JutoushePlugin struct {
+	plugin.Base
 	client *http.Client
 }
 
-func NewJutoushePlugin(client *http.Client) *JutoushePlugin {
-	return &JutoushePlugin{client: client}
+var jutousheInfo = plugin.Info{
+	Name:        "jutoushe",
+	DisplayName: "剧透社",
+	Description: "剧透社 - 影视资源搜索",
+	Priority:    1,
+	Enabled:     true,
+	CloudTypes:  []string{"baidu", "aliyun", "quark", "tianyi", "uc", "xunlei", "115", "pikpak", "123", "magnet", "ed2k"},
 }
 
-func (p *JutoushePlugin) Name() string        { return "jutoushe" }
-func (p *JutoushePlugin) DisplayName() string { return "剧透社" }
-func (p *JutoushePlugin) Description() string { return "剧透社 - 影视资源搜索" }
-func (p *JutoushePlugin) Priority() int       { return 1 }
+func init() {
+	plugin.Register(jutousheInfo, func(client *http.Client) plugin.Plugin {
+		return NewJutoushePlugin(client)
+	})
+}
 
-func (p *JutoushePlugin) Search(keyword string, ext map[string]interface{}) ([]model.SearchResult, error) {
-	searchURL := fmt.Sprintf("%s/search.html?wd=%s", jutousheBaseURL, url.QueryEscape(keyword))
+func NewJutoushePlugin(client *http.Client) *JutoushePlugin {
+	return &JutoushePlugin{Base: plugin.NewBase(jutousheInfo), client: client}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func (p *JutoushePlugin) Search(ctx context.Context, keyword string, ext map[string]interface{}) ([]model.SearchResult, error) {
+	searchURL := fmt.Sprintf("%s/search.html?wd=%s", jutousheBaseURL, url.QueryEscape(keyword))
 
 	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
@@ -67,33 +75,13 @@ func (p *JutoushePlugin) Search(keyword string, ext map[string]interface{}) ([]m
 		}
 
 		description := strings.TrimSpace(s.Find(".description").Text())
-		links := make([]model.Link, 0)
-
-		s.Find("a[href]").Each(func(j int, link *goquery.Selection) {
-			href, _ := link.Attr("href")
-			if href == "" {
-				return
-			}
-
-			cloudType := detectCloudType(href)
-			if cloudType == "" {
-				return
-			}
+		shareLinks := links.FromHTML(s)
 
-			password := extractPassword(link.Text() + " " + description)
-
-			links = append(links, model.Link{
-				Type:     cloudType,
-				URL:      href,
-				Password: password,
-			})
-		})
-
-		if len(links) > 0 {
+		if len(shareLinks) > 0 {
 			results = append(results, model.SearchResult{
 				Title:       title,
 				Description: description,
-				Links:       links,
+				Links:       shareLinks,
 				Source:      "plugin:jutoushe",
 			})
 		}
@@ -101,37 +89,3 @@ func (p *JutoushePlugin) Search(keyword string, ext map[string]interface{}) ([]m
 
 	return results, nil
 }
-
-func detectCloudType(urlStr string) string {
-	if strings.Contains(urlStr, "pan.baidu.com") {
-		return "baidu"
-	} else if strings.Contains(urlStr, "aliyundrive.com") || strings.Contains(urlStr, "alipan.com") {
-		return "aliyun"
-	} else if strings.Contains(urlStr, "pan.quark.cn") {
-		return "quark"
-	} else if strings.Contains(urlStr, "cloud.189.cn") {
-		return "tianyi"
-	} else if strings.Contains(urlStr, "pan.xunlei.com") {
-		return "xunlei"
-	} else if strings.Contains(urlStr, "115.com") {
-		return "115"
-	} else if strings.Contains(urlStr, "pikpak.com") {
-		return "pikpak"
-	} else if strings.Contains(urlStr, "123pan.com") {
-		return "123"
-	} else if strings.HasPrefix(urlStr, "magnet:") {
-		return "magnet"
-	} else if strings.HasPrefix(urlStr, "ed2k:") {
-		return "ed2k"
-	}
-	return ""
-}
-
-func extractPassword(text string) string {
-	re := regexp.MustCompile(`(?:提取码|密码|code|Code)[：:]\s*([a-zA-Z0-9]{4})`)
-	matches := re.FindStringSubmatch(text)
-	if len(matches) > 1 {
-		return matches[1]
-	}
-	return ""
-}
@@ -7,9 +7,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"time"
 
+	"pansou-openwrt/internal/links"
 	"pansou-openwrt/internal/model"
+	"pansou-openwrt/internal/plugin"
 )
 
 const (
@@ -31,23 +32,31 @@ type MiaosouResponse struct {
 }
 
 type MiaosoPlugin struct {
+	plugin.Base
 	client *http.Client
 }
 
-func NewMiaosoPlugin(client *http.Client) *MiaosoPlugin {
-	return &MiaosoPlugin{client: client}
+var miaosoInfo = plugin.Info{
+	Name:        "miaoso",
+	DisplayName: "喵搜",
+	Description: "喵搜 - 多网盘搜索引擎",
+	Priority:    3,
+	Enabled:     true,
+	CloudTypes:  []string{"baidu", "aliyun", "quark", "tianyi", "xunlei", "115", "pikpak", "123"},
 }
 
-func (p *MiaosoPlugin) Name() string        { return "miaoso" }
-func (p *MiaosoPlugin) DisplayName() string { return "喵搜" }
-func (p *MiaosoPlugin) Description() string { return "喵搜 - 多网盘搜索引擎" }
-func (p *MiaosoPlugin) Priority() int       { return 3 }
+func init() {
+	plugin.Register(miaosoInfo, func(client *http.Client) plugin.Plugin {
+		return NewMiaosoPlugin(client)
+	})
+}
 
-func (p *MiaosoPlugin) Search(keyword string, ext map[string]interface{}) ([]model.SearchResult, error) {
-	searchURL := fmt.Sprintf("%s?name=%s&pageNo=1", miaosoBaseURL, url.QueryEscape(keyword))
+func NewMiaosoPlugin(client *http.Client) *MiaosoPlugin {
+	return &MiaosoPlugin{Base: plugin.NewBase(miaosoInfo), client: client}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func (p *MiaosoPlugin) Search(ctx context.Context, keyword string, ext map[string]interface{}) ([]model.SearchResult, error) {
+	searchURL := fmt.Sprintf("%s?name=%s&pageNo=1", miaosoBaseURL, url.QueryEscape(keyword))
 
 	req, err := http.NewRequestWithContext(ctx, "GET", searchURL, nil)
 	if err != nil {
@@ -83,22 +92,23 @@ func (p *MiaosoPlugin) Search(keyword string, ext map[string]interface{}) ([]mod
 
 	results := make([]model.SearchResult, 0)
 	for _, item := range apiResp.Data.List {
-		cloudType := mapMiaosoType(item.Type)
-		if cloudType == "" {
+		// 类型编号未知时根据URL判断
+		link, ok := links.Parse(item.Url)
+		if cloudType := mapMiaosoType(item.Type); cloudType != "" {
+			link.Type, link.URL, ok = cloudType, item.Url, true
+		}
+		if !ok {
 			continue
 		}
+		if item.Password != "" {
+			link.Password = item.Password
+		}
 
 		results = append(results, model.SearchResult{
 			Title:       item.Title,
 			Description: item.Description,
-			Links: []model.Link{
-				{
-					Type:     cloudType,
-					URL:      item.Url,
-					Password: item.Password,
-				},
-			},
-			Source: "plugin:miaoso",
+			Links:       []model.Link{link},
+			Source:      "plugin:miaoso",
 		})
 	}
 
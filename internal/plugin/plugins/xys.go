@@ -3,19 +3,20 @@ package plugins
 import (
 	"context"
 	"encoding/base64"
-	encoding_json "encoding/json"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
-	"pan-sou/internal/plugin"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+	"pansou-openwrt/internal/links"
+	"pansou-openwrt/internal/model"
+	"pansou-openwrt/internal/plugin"
 )
 
 const (
@@ -24,91 +25,78 @@ const (
 	xysSearchPath = "/api/validate/searchX2"
 	xysUserAgent  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/138.0.0.0 Safari/537.36"
 	xysMaxResults = 50
+	xysTokenTTL   = 30 * time.Minute
+)
+
+var (
+	xysTokenRegex = regexp.MustCompile(`const\s+DToken\s*=\s*"([^"]+)"`)
+	xysTagRegex   = regexp.MustCompile(`<[^>]*>`)
+	xysSpaceRegex = regexp.MustCompile(`\s+`)
 )
 
 // XysPlugin 小云搜索插件
 type XysPlugin struct {
-	debugMode  bool
+	plugin.Base
+	client     *http.Client
 	tokenCache sync.Map
-	cacheTTL   time.Duration
 }
 
-// TokenCache token缓存结构
-type TokenCache struct {
+// XysTokenCache token缓存结构
+type XysTokenCache struct {
 	Token     string
 	Timestamp time.Time
 }
 
-// SearchResponse API响应结构
-type SearchResponse struct {
+// XysSearchResponse API响应结构
+type XysSearchResponse struct {
 	Code int    `json:"code"`
 	Msg  string `json:"msg"`
 	Time string `json:"time"`
 	Data string `json:"data"`
 }
 
-func init() {
-	plugin.Register("xys", &XysPlugin{
-		debugMode: false,
-		cacheTTL:  30 * time.Minute,
-	})
+var xysInfo = plugin.Info{
+	Name:        "xys",
+	DisplayName: "小云搜索",
+	Description: "小云搜索 - 多网盘搜索引擎",
+	Priority:    2,
+	Enabled:     true,
+	CloudTypes:  []string{"aliyun", "quark", "baidu", "xunlei", "uc"},
 }
 
-func (p *XysPlugin) Name() string {
-	return "小云搜索"
+func init() {
+	plugin.Register(xysInfo, func(client *http.Client) plugin.Plugin {
+		return NewXysPlugin(client)
+	})
 }
 
-func (p *XysPlugin) Description() string {
-	return "小云搜索 - 阿里云盘、夸克网盘、百度网盘等多网盘搜索引擎"
+func NewXysPlugin(client *http.Client) *XysPlugin {
+	return &XysPlugin{Base: plugin.NewBase(xysInfo), client: client}
 }
 
-func (p *XysPlugin) Search(ctx context.Context, keyword string) ([]plugin.SearchResult, error) {
-	if p.debugMode {
-		log.Printf("[XYS] 开始搜索: %s", keyword)
-	}
-
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			MaxIdleConnsPerHost: 10,
-			IdleConnTimeout:     90 * time.Second,
-		},
-	}
-
+func (p *XysPlugin) Search(ctx context.Context, keyword string, ext map[string]interface{}) ([]model.SearchResult, error) {
 	// 第一步：获取token
-	token, err := p.getToken(client, keyword)
+	token, err := p.getToken(ctx, keyword)
 	if err != nil {
 		return nil, fmt.Errorf("获取token失败: %w", err)
 	}
 
-	if p.debugMode {
-		log.Printf("[XYS] 获取到token: %s", token[:10]+"...")
-	}
-
 	// 第二步：执行搜索
-	results, err := p.executeSearch(client, token, keyword)
+	results, err := p.executeSearch(ctx, token, keyword)
 	if err != nil {
 		return nil, fmt.Errorf("执行搜索失败: %w", err)
 	}
 
-	if p.debugMode {
-		log.Printf("[XYS] 搜索完成，获取到 %d 个结果", len(results))
-	}
-
 	return results, nil
 }
 
 // getToken 获取搜索token
-func (p *XysPlugin) getToken(client *http.Client, keyword string) (string, error) {
+func (p *XysPlugin) getToken(ctx context.Context, keyword string) (string, error) {
 	// 检查缓存
 	cacheKey := "token"
 	if cached, found := p.tokenCache.Load(cacheKey); found {
-		if tokenCache, ok := cached.(TokenCache); ok {
-			if time.Since(tokenCache.Timestamp) < p.cacheTTL {
-				if p.debugMode {
-					log.Printf("[XYS] 使用缓存的token")
-				}
+		if tokenCache, ok := cached.(XysTokenCache); ok {
+			if time.Since(tokenCache.Timestamp) < xysTokenTTL {
 				return tokenCache.Token, nil
 			}
 		}
@@ -118,9 +106,6 @@ func (p *XysPlugin) getToken(client *http.Client, keyword string) (string, error
 	tokenURL := fmt.Sprintf("%s%s?wd=%s&mode=undefined&stype=undefined",
 		xysBaseURL, xysTokenPath, url.QueryEscape(keyword))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	req, err := http.NewRequestWithContext(ctx, "GET", tokenURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("创建token请求失败: %w", err)
@@ -135,16 +120,12 @@ func (p *XysPlugin) getToken(client *http.Client, keyword string) (string, error
 	req.Header.Set("Cache-Control", "max-age=0")
 	req.Header.Set("Referer", xysBaseURL+"/")
 
-	resp, err := p.doRequestWithRetry(req, client)
+	resp, err := p.doRequestWithRetry(req)
 	if err != nil {
 		return "", fmt.Errorf("token请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("token请求HTTP状态错误: %d", resp.StatusCode)
-	}
-
 	// 解析HTML提取token
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
@@ -156,13 +137,8 @@ func (p *XysPlugin) getToken(client *http.Client, keyword string) (string, error
 	doc.Find("script").Each(func(i int, s *goquery.Selection) {
 		scriptContent := s.Text()
 		if strings.Contains(scriptContent, "DToken") {
-			re := regexp.MustCompile(`const\s+DToken\s*=\s*"([^"]+)"`)
-			matches := re.FindStringSubmatch(scriptContent)
-			if len(matches) > 1 {
+			if matches := xysTokenRegex.FindStringSubmatch(scriptContent); len(matches) > 1 {
 				token = matches[1]
-				if p.debugMode {
-					log.Printf("[XYS] 从script中提取到token: %s", token[:10]+"...")
-				}
 			}
 		}
 	})
@@ -172,7 +148,7 @@ func (p *XysPlugin) getToken(client *http.Client, keyword string) (string, error
 	}
 
 	// 缓存token
-	p.tokenCache.Store(cacheKey, TokenCache{
+	p.tokenCache.Store(cacheKey, XysTokenCache{
 		Token:     token,
 		Timestamp: time.Now(),
 	})
@@ -181,40 +157,40 @@ func (p *XysPlugin) getToken(client *http.Client, keyword string) (string, error
 }
 
 // doRequestWithRetry 带重试机制的HTTP请求
-func (p *XysPlugin) doRequestWithRetry(req *http.Request, client *http.Client) (*http.Response, error) {
+func (p *XysPlugin) doRequestWithRetry(req *http.Request) (*http.Response, error) {
 	maxRetries := 3
 	var lastErr error
 
 	for i := 0; i < maxRetries; i++ {
 		if i > 0 {
 			backoff := time.Duration(1<<uint(i-1)) * 200 * time.Millisecond
-			time.Sleep(backoff)
+			if !sleepContext(req.Context(), backoff) {
+				return nil, req.Context().Err()
+			}
 		}
 
-		reqClone := req.Clone(req.Context())
-		resp, err := client.Do(reqClone)
-		if err == nil && resp.StatusCode == 200 {
+		resp, err := p.client.Do(req.Clone(req.Context()))
+		if err == nil && resp.StatusCode == http.StatusOK {
 			return resp, nil
 		}
 
 		if resp != nil {
 			resp.Body.Close()
+			lastErr = fmt.Errorf("HTTP状态码: %d", resp.StatusCode)
+		} else {
+			lastErr = err
 		}
-		lastErr = err
 	}
 
 	return nil, fmt.Errorf("重试 %d 次后仍然失败: %w", maxRetries, lastErr)
 }
 
 // executeSearch 执行搜索请求
-func (p *XysPlugin) executeSearch(client *http.Client, token, keyword string) ([]plugin.SearchResult, error) {
+func (p *XysPlugin) executeSearch(ctx context.Context, token, keyword string) ([]model.SearchResult, error) {
 	// 构建搜索URL
 	searchURL := fmt.Sprintf("%s%s?DToken2=%s&requestID=undefined&mode=90002&stype=undefined&scope_content=0&wd=%s&uk=&page=1&limit=20&screen_filetype=",
 		xysBaseURL, xysSearchPath, token, url.QueryEscape(keyword))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	req, err := http.NewRequestWithContext(ctx, "POST", searchURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("创建搜索请求失败: %w", err)
@@ -230,16 +206,12 @@ func (p *XysPlugin) executeSearch(client *http.Client, token, keyword string) ([
 	req.Header.Set("Origin", xysBaseURL)
 	req.Header.Set("X-Requested-With", "XMLHttpRequest")
 
-	resp, err := p.doRequestWithRetry(req, client)
+	resp, err := p.doRequestWithRetry(req)
 	if err != nil {
 		return nil, fmt.Errorf("搜索请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("搜索请求HTTP状态错误: %d", resp.StatusCode)
-	}
-
 	// 读取响应体
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -247,31 +219,29 @@ func (p *XysPlugin) executeSearch(client *http.Client, token, keyword string) ([
 	}
 
 	// 解析JSON响应
-	var searchResp SearchResponse
-	if err := encoding_json.Unmarshal(respBody, &searchResp); err != nil {
+	var searchResp XysSearchResponse
+	if err := json.Unmarshal(respBody, &searchResp); err != nil {
 		return nil, fmt.Errorf("JSON解析失败: %w", err)
 	}
 
 	if searchResp.Code != 0 {
+		// token 可能已失效，下次重新获取
+		p.tokenCache.Delete("token")
 		return nil, fmt.Errorf("搜索API返回错误: %s", searchResp.Msg)
 	}
 
-	if p.debugMode {
-		log.Printf("[XYS] 搜索API响应成功，data长度: %d", len(searchResp.Data))
-	}
-
 	// 解析HTML内容
 	return p.parseSearchResults(searchResp.Data, keyword)
 }
 
 // parseSearchResults 解析搜索结果HTML
-func (p *XysPlugin) parseSearchResults(htmlData, keyword string) ([]plugin.SearchResult, error) {
+func (p *XysPlugin) parseSearchResults(htmlData, keyword string) ([]model.SearchResult, error) {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlData))
 	if err != nil {
 		return nil, fmt.Errorf("解析搜索结果HTML失败: %w", err)
 	}
 
-	var results []plugin.SearchResult
+	results := make([]model.SearchResult, 0)
 
 	// 查找搜索结果项
 	doc.Find(".layui-card[data-qid]").Each(func(i int, s *goquery.Selection) {
@@ -279,28 +249,17 @@ func (p *XysPlugin) parseSearchResults(htmlData, keyword string) ([]plugin.Searc
 			return
 		}
 
-		result := p.parseResultItem(s, i+1)
-		if result != nil {
+		if result := p.parseResultItem(s); result != nil {
 			results = append(results, *result)
 		}
 	})
 
-	if p.debugMode {
-		log.Printf("[XYS] 解析到 %d 个原始结果", len(results))
-	}
-
 	// 关键词过滤
-	filteredResults := filterResultsByKeyword(results, keyword)
-
-	if p.debugMode {
-		log.Printf("[XYS] 关键词过滤后剩余 %d 个结果", len(filteredResults))
-	}
-
-	return filteredResults, nil
+	return filterByKeyword(results, keyword), nil
 }
 
 // parseResultItem 解析单个搜索结果项
-func (p *XysPlugin) parseResultItem(s *goquery.Selection, index int) *plugin.SearchResult {
+func (p *XysPlugin) parseResultItem(s *goquery.Selection) *model.SearchResult {
 	// 提取QID
 	qid, _ := s.Attr("data-qid")
 	if qid == "" {
@@ -313,17 +272,15 @@ func (p *XysPlugin) parseResultItem(s *goquery.Selection, index int) *plugin.Sea
 		return nil
 	}
 
-	// 提取标题
 	title := p.cleanTitle(linkEl.Text())
 	if title == "" {
 		return nil
 	}
 
-	// 提取链接URL
+	// 提取链接URL，部分结果的链接经过base64编码放在url属性中
 	href, _ := linkEl.Attr("href")
 	if href == "" {
-		urlAttr, _ := linkEl.Attr("url")
-		if urlAttr != "" {
+		if urlAttr, _ := linkEl.Attr("url"); urlAttr != "" {
 			if decoded, err := base64.StdEncoding.DecodeString(urlAttr); err == nil {
 				href = string(decoded)
 			}
@@ -331,115 +288,65 @@ func (p *XysPlugin) parseResultItem(s *goquery.Selection, index int) *plugin.Sea
 	}
 
 	if href == "" {
-		if p.debugMode {
-			log.Printf("[XYS] 跳过无链接的结果: %s", title)
-		}
 		return nil
 	}
 
-	// 提取密码
-	password, _ := linkEl.Attr("pa")
-
-	// 提取时间
-	timeStr := strings.TrimSpace(s.Find(".layui-icon-time").Parent().Text())
-	publishTime := p.parseTime(timeStr)
-
-	// 提取网盘类型
-	platform := p.extractPlatform(s, href)
+	cloudType := p.extractPlatform(s, href)
+	if cloudType == "" {
+		return nil
+	}
 
-	return &plugin.SearchResult{
-		Title:     title,
-		Url:       href,
-		Password:  password,
-		CloudType: platform,
-		Size:      "",
-		CreatedAt: publishTime,
+	// 提取密码和时间
+	password, _ := linkEl.Attr("pa")
+	if password == "" {
+		password = links.URLPassword(href)
+	}
+	publishTime := parseTime(s.Find(".layui-icon-time").Parent().Text())
+
+	return &model.SearchResult{
+		UniqueID: "xys:" + qid,
+		Title:    title,
+		Links: []model.Link{
+			{
+				Type:     cloudType,
+				URL:      href,
+				Password: password,
+			},
+		},
+		Source:      "plugin:xys",
+		PublishTime: publishTime,
+		Datetime:    formatTime(publishTime),
 	}
 }
 
 // cleanTitle 清理标题
 func (p *XysPlugin) cleanTitle(title string) string {
-	if title == "" {
-		return ""
-	}
-
-	// 移除HTML标签
-	re := regexp.MustCompile(`<[^>]*>`)
-	cleaned := re.ReplaceAllString(title, "")
-
-	// 移除@符号
+	cleaned := xysTagRegex.ReplaceAllString(title, "")
 	cleaned = strings.ReplaceAll(cleaned, "@", "")
-
-	// 清理多余的空格
-	cleaned = strings.TrimSpace(cleaned)
-	re = regexp.MustCompile(`\s+`)
-	cleaned = re.ReplaceAllString(cleaned, " ")
-
-	return cleaned
-}
-
-// parseTime 解析时间字符串
-func (p *XysPlugin) parseTime(timeStr string) string {
-	timeStr = strings.TrimSpace(timeStr)
-	if timeStr == "" {
-		return time.Now().Format("2006-01-02 15:04:05")
-	}
-
-	// 常见格式
-	formats := []string{
-		"2006-01-02 15:04:05",
-		"2006-01-02",
-		"2006/01/02",
-		"01-02 15:04",
-	}
-
-	for _, format := range formats {
-		if t, err := time.Parse(format, timeStr); err == nil {
-			return t.Format("2006-01-02 15:04:05")
-		}
-	}
-
-	return time.Now().Format("2006-01-02 15:04:05")
+	return strings.TrimSpace(xysSpaceRegex.ReplaceAllString(cleaned, " "))
 }
 
 // extractPlatform 提取网盘平台类型
 func (p *XysPlugin) extractPlatform(s *goquery.Selection, href string) string {
-	// 检查显示的平台标签
-	platformText := strings.TrimSpace(s.Find(".layui-badge-rim").Text())
-	if platformText != "" {
-		switch {
-		case strings.Contains(platformText, "阿里"):
-			return "aliyun"
-		case strings.Contains(platformText, "夸克"):
-			return "quark"
-		case strings.Contains(platformText, "百度"):
-			return "baidu"
-		case strings.Contains(platformText, "迅雷"):
-			return "xunlei"
-		case strings.Contains(platformText, "UC"):
-			return "uc"
-		}
-	}
-
-	// 从URL判断
-	return detectCloudType(href)
-}
-
-// filterResultsByKeyword 过滤搜索结果
-func filterResultsByKeyword(results []plugin.SearchResult, keyword string) []plugin.SearchResult {
-	if keyword == "" {
-		return results
-	}
-
-	keyword = strings.ToLower(keyword)
-	var filtered []plugin.SearchResult
-
-	for _, result := range results {
-		title := strings.ToLower(result.Title)
-		if strings.Contains(title, keyword) {
-			filtered = append(filtered, result)
-		}
+	// 优先从URL判断
+	if cloudType := links.Type(href); cloudType != "" {
+		return cloudType
 	}
 
-	return filtered
+	// 检查显示的平台标签
+	platformText := strings.TrimSpace(s.Find(".layui-badge-rim").Text())
+	switch {
+	case strings.Contains(platformText, "阿里"):
+		return "aliyun"
+	case strings.Contains(platformText, "夸克"):
+		return "quark"
+	case strings.Contains(platformText, "百度"):
+		return "baidu"
+	case strings.Contains(platformText, "迅雷"):
+		return "xunlei"
+	case strings.Contains(platformText, "UC"):
+		return "uc"
+	}
+
+	return ""
 }
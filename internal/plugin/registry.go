@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Info 插件元数据，由插件在 init() 中注册
+type Info struct {
+	Name        string   `json:"name"`         // 插件唯一标识（英文）
+	DisplayName string   `json:"display_name"` // 显示名称（中文）
+	Description string   `json:"description"`  // 插件描述
+	Priority    int      `json:"priority"`     // 默认优先级（1-3，1最高）
+	Enabled     bool     `json:"enabled"`      // 默认是否启用
+	CloudTypes  []string `json:"cloud_types"`  // 可能返回的网盘类型
+}
+
+// Factory 使用共享HTTP客户端创建插件实例
+type Factory func(client *http.Client) Plugin
+
+type registration struct {
+	info    Info
+	factory Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registration)
+)
+
+// Register 注册插件，通常在插件文件的 init() 中调用
+func Register(info Info, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if info.Name == "" {
+		panic("plugin: 插件名称不能为空")
+	}
+	if _, exists := registry[info.Name]; exists {
+		panic(fmt.Sprintf("plugin: 插件 %s 重复注册", info.Name))
+	}
+
+	registry[info.Name] = registration{info: info, factory: factory}
+}
+
+// Registered 返回所有已注册插件的元数据（按名称排序）
+func Registered() []Info {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	infos := make([]Info, 0, len(registry))
+	for _, r := range registry {
+		infos = append(infos, r.info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// Lookup 查找已注册插件的元数据
+func Lookup(name string) (Info, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	r, ok := registry[name]
+	return r.info, ok
+}
+
+// Base 实现 Plugin 接口中的元数据方法，插件结构体嵌入后只需实现 Search
+type Base struct {
+	info Info
+}
+
+// NewBase 创建插件元数据
+func NewBase(info Info) Base {
+	return Base{info: info}
+}
+
+func (b Base) Name() string        { return b.info.Name }
+func (b Base) DisplayName() string { return b.info.DisplayName }
+func (b Base) Description() string { return b.info.Description }
+func (b Base) Priority() int       { return b.info.Priority }
+func (b Base) Info() Info          { return b.info }
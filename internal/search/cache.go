@@ -0,0 +1,137 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"pansou-openwrt/internal/cache"
+	"pansou-openwrt/internal/config"
+	"pansou-openwrt/internal/model"
+)
+
+// newCache 按配置创建搜索结果缓存：内存LRU层，配置了 CacheDir 时再加一层磁盘缓存
+func newCache(cfg *config.SearchConfig) *cache.Cache {
+	tiers := []cache.Backend{
+		cache.NewLRU(cfg.CacheMaxEntries, int64(cfg.CacheMaxMemory)<<20),
+	}
+
+	if cfg.CacheDir != "" {
+		file, err := cache.NewFile(cfg.CacheDir, 0, int64(cfg.CacheDirMaxSize)<<20)
+		if err != nil {
+			log.Printf("[缓存] 磁盘缓存不可用，只使用内存缓存: %v", err)
+		} else {
+			tiers = append(tiers, file)
+		}
+	}
+
+	return cache.New(tiers...)
+}
+
+// cacheKeyFields 参与缓存键计算的请求字段
+// Concurrency 和 ForceRefresh 不影响搜索结果，不参与计算
+type cacheKeyFields struct {
+	Keyword    string                 `json:"k"`
+	SourceType string                 `json:"s"`
+	ResultType string                 `json:"r"`
+	Plugins    []string               `json:"p,omitempty"`
+	Channels   []string               `json:"c,omitempty"`
+	CloudTypes []string               `json:"t,omitempty"`
+	Ext        map[string]interface{} `json:"e,omitempty"`
+}
+
+// buildCacheKey 根据规范化后的完整请求构建缓存键
+// 关键词忽略大小写和多余空白，列表参数去重排序，不参与本次搜索的来源的参数（如只搜TG时的插件列表）被忽略
+func buildCacheKey(req *model.SearchRequest) string {
+	fields := cacheKeyFields{
+		Keyword:    strings.ToLower(strings.Join(strings.Fields(req.Keyword), " ")),
+		SourceType: req.SourceType,
+		ResultType: req.ResultType,
+		CloudTypes: normalizeList(req.CloudTypes),
+		Ext:        req.Ext,
+	}
+	if fields.SourceType == "" {
+		fields.SourceType = "all"
+	}
+	if fields.ResultType == "" {
+		fields.ResultType = "merge"
+	}
+	if fields.SourceType == "all" || fields.SourceType == "plugin" {
+		fields.Plugins = normalizeList(req.Plugins)
+	}
+	if fields.SourceType == "all" || fields.SourceType == "tg" {
+		fields.Channels = normalizeList(req.Channels)
+	}
+	if len(fields.Ext) == 0 {
+		fields.Ext = nil
+	}
+
+	// encoding/json 按键名排序输出map，相同的Ext总是得到相同的结果
+	data, err := json.Marshal(fields)
+	if err != nil {
+		// Ext 中含有无法编码的值时退化为不含Ext的键
+		fields.Ext = nil
+		data, _ = json.Marshal(fields)
+	}
+	return "search:" + string(data)
+}
+
+// normalizeList 去除空白和重复项并排序，不修改原切片
+func normalizeList(list []string) []string {
+	if len(list) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(list))
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		result = append(result, item)
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	sort.Strings(result)
+	return result
+}
+
+// getCached 读取缓存的搜索结果，fresh 表示结果是否仍在有效期内
+// 缓存中保存的是编码后的数据，每次命中都解码出独立的副本，调用方修改 CacheHit、SearchTime 等字段不会影响其他请求
+func (s *Service) getCached(key string) (resp *model.SearchResponse, fresh bool, ok bool) {
+	data, fresh, ok := s.cache.Get(key)
+	if !ok {
+		return nil, false, false
+	}
+
+	resp, err := decodeResponse(data)
+	if err != nil {
+		log.Printf("[缓存] %v", err)
+		s.cache.Delete(key)
+		return nil, false, false
+	}
+	return resp, fresh, true
+}
+
+// encodeResponse 编码搜索响应用于缓存
+func encodeResponse(resp *model.SearchResponse) ([]byte, error) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("编码搜索结果失败: %w", err)
+	}
+	return data, nil
+}
+
+// decodeResponse 解码出一份独立的搜索响应
+func decodeResponse(data []byte) (*model.SearchResponse, error) {
+	var resp model.SearchResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("解析缓存失败: %w", err)
+	}
+	return &resp, nil
+}
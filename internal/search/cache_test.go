@@ -0,0 +1,208 @@
+package search
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"pansou-openwrt/internal/config"
+	"pansou-openwrt/internal/linkcheck"
+	"pansou-openwrt/internal/model"
+)
+
+// newTestService 创建只有缓存的搜索服务，不启用TG和插件
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Search.CacheMaxEntries = 100
+	cfg.Search.CacheMaxMemory = 4
+	cfg.Search.ValidateLinks = linkcheck.ModeOff
+	cfg.Search.Timeout = 5
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Service{
+		cache:   newCache(&cfg.Search),
+		checker: linkcheck.New(http.DefaultClient, linkcheck.DefaultEndpoints),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	s.config.Store(cfg)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// putCached 把搜索响应写入缓存
+func putCached(t *testing.T, s *Service, key string, resp *model.SearchResponse) {
+	t.Helper()
+
+	data, err := encodeResponse(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.cache.Set(key, data, time.Minute, time.Minute)
+}
+
+func testResponse() *model.SearchResponse {
+	results := []model.SearchResult{{
+		UniqueID: "tg:a:1",
+		Title:    "三体 全集",
+		Links: []model.Link{
+			{Type: "quark", URL: "https://pan.quark.cn/s/abc"},
+		},
+		Source: "tg:a",
+	}}
+	return &model.SearchResponse{
+		Total:        1,
+		Results:      results,
+		MergedByType: map[string][]model.SearchResult{"quark": results},
+	}
+}
+
+// mutate 修改响应中所有可共享的部分
+func mutate(resp *model.SearchResponse) {
+	resp.CacheHit = false
+	resp.SearchTime = 1
+	resp.Total = 0
+	for i := range resp.Results {
+		resp.Results[i].Title = "changed"
+		resp.Results[i].Links[0].URL = "changed"
+		resp.Results[i].Links = append(resp.Results[i].Links, model.Link{URL: "extra"})
+	}
+	for typ, bucket := range resp.MergedByType {
+		bucket[0].Links[0].Status = "expired"
+		resp.MergedByType[typ] = nil
+	}
+	resp.MergedByType["others"] = nil
+}
+
+func TestGetCachedReturnsIndependentCopies(t *testing.T) {
+	s := newTestService(t)
+	req := &model.SearchRequest{Keyword: "三体", ResultType: "all"}
+	key := buildCacheKey(req)
+	putCached(t, s, key, testResponse())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			resp, fresh, ok := s.getCached(key)
+			if !ok || !fresh {
+				t.Errorf("getCached: ok=%v fresh=%v", ok, fresh)
+				return
+			}
+			mutate(resp)
+		}()
+		go func() {
+			defer wg.Done()
+			resp, err := s.Search(context.Background(), req)
+			if err != nil {
+				t.Errorf("Search: %v", err)
+				return
+			}
+			if !resp.CacheHit {
+				t.Error("Search: 缓存未命中")
+			}
+			mutate(resp)
+		}()
+	}
+	wg.Wait()
+
+	resp, _, ok := s.getCached(key)
+	if !ok {
+		t.Fatal("缓存条目丢失")
+	}
+	if resp.Total != 1 || resp.CacheHit || len(resp.Results) != 1 || len(resp.MergedByType) != 1 {
+		t.Fatalf("缓存被修改: %+v", resp)
+	}
+	link := resp.MergedByType["quark"][0].Links
+	if len(link) != 1 || link[0].URL != "https://pan.quark.cn/s/abc" || link[0].Status != "" {
+		t.Fatalf("缓存中的链接被修改: %+v", link)
+	}
+	if resp.Results[0].Title != "三体 全集" {
+		t.Fatalf("缓存中的标题被修改: %q", resp.Results[0].Title)
+	}
+}
+
+func TestBuildCacheKeyNormalization(t *testing.T) {
+	base := &model.SearchRequest{
+		Keyword:    "Three Body",
+		Plugins:    []string{"xys", "labi"},
+		Channels:   []string{"tgsearchers", "Aliyun_4K_Movies"},
+		CloudTypes: []string{"quark", "baidu"},
+	}
+	key := buildCacheKey(base)
+
+	same := []*model.SearchRequest{
+		{
+			Keyword:    "  three   BODY ",
+			Plugins:    []string{"labi", "xys"},
+			Channels:   []string{"Aliyun_4K_Movies", "tgsearchers"},
+			CloudTypes: []string{"baidu", "quark"},
+		},
+		{
+			Keyword:    "THREE\tbody",
+			Plugins:    []string{"labi", "xys", "labi", " "},
+			Channels:   []string{"tgsearchers", "Aliyun_4K_Movies", "tgsearchers"},
+			CloudTypes: []string{"baidu", "quark", "baidu"},
+			SourceType: "all",
+			ResultType: "merge",
+		},
+		{
+			Keyword:      "three body",
+			Plugins:      []string{"xys", "labi"},
+			Channels:     []string{"tgsearchers", "Aliyun_4K_Movies"},
+			CloudTypes:   []string{"quark", "baidu"},
+			Concurrency:  8,
+			ForceRefresh: true,
+		},
+	}
+	for i, req := range same {
+		if got := buildCacheKey(req); got != key {
+			t.Errorf("case %d: 缓存键不同\n got: %s\nwant: %s", i, got, key)
+		}
+	}
+}
+
+func TestBuildCacheKeyDistinguishesExtAndSource(t *testing.T) {
+	base := model.SearchRequest{
+		Keyword:  "三体",
+		Plugins:  []string{"xys"},
+		Channels: []string{"tgsearchers"},
+		Ext:      map[string]interface{}{"title_en": "Three Body"},
+	}
+
+	variants := map[string]func(r *model.SearchRequest){
+		"ext 值不同":     func(r *model.SearchRequest) { r.Ext = map[string]interface{}{"title_en": "The Three Body"} },
+		"ext 键不同":     func(r *model.SearchRequest) { r.Ext = map[string]interface{}{"year": "2023"} },
+		"没有 ext":      func(r *model.SearchRequest) { r.Ext = nil },
+		"只搜TG":        func(r *model.SearchRequest) { r.SourceType = "tg" },
+		"只搜插件":        func(r *model.SearchRequest) { r.SourceType = "plugin" },
+		"插件不同":        func(r *model.SearchRequest) { r.Plugins = []string{"labi"} },
+		"频道不同":        func(r *model.SearchRequest) { r.Channels = []string{"Aliyun_4K_Movies"} },
+		"result_type": func(r *model.SearchRequest) { r.ResultType = "results" },
+	}
+
+	seen := map[string]string{buildCacheKey(&base): "base"}
+	for name, change := range variants {
+		req := base
+		change(&req)
+		key := buildCacheKey(&req)
+		if prev, ok := seen[key]; ok {
+			t.Errorf("%s 与 %s 的缓存键相同: %s", name, prev, key)
+		}
+		seen[key] = name
+	}
+
+	// 不参与本次搜索的来源的参数不影响缓存键
+	tgOnly := base
+	tgOnly.SourceType = "tg"
+	other := tgOnly
+	other.Plugins = []string{"labi"}
+	if buildCacheKey(&tgOnly) != buildCacheKey(&other) {
+		t.Error("只搜TG时插件列表不应影响缓存键")
+	}
+}
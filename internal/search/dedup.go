@@ -0,0 +1,240 @@
+package search
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+
+	"pansou-openwrt/internal/links"
+	"pansou-openwrt/internal/model"
+)
+
+// trackingParams 分享链接中与资源无关的跟踪参数
+var trackingParams = map[string]bool{
+	"from":         true,
+	"source":       true,
+	"spm":          true,
+	"share_source": true,
+	"share_from":   true,
+	"entry":        true,
+	"channel":      true,
+	"ref":          true,
+	"_t":           true,
+	"fbclid":       true,
+	"gclid":        true,
+}
+
+// passwordParams 链接中携带提取码的参数
+var passwordParams = []string{"pwd", "password", "passcode", "code"}
+
+// hostAliases 同一网盘的不同域名
+var hostAliases = map[string]string{
+	"aliyundrive.com": "alipan.com",
+	"yun.baidu.com":   "pan.baidu.com",
+	"115cdn.com":      "115.com",
+	"anxia.com":       "115.com",
+}
+
+// dedupResults 按规范化链接合并不同来源的重复结果
+// 保留最先出现的结果，合并所有来源，并从重复结果中补全缺失的提取码、发布时间和描述
+func dedupResults(results []model.SearchResult) []model.SearchResult {
+	merged := make([]model.SearchResult, 0, len(results))
+	index := make(map[string]int) // 规范化链接 -> merged 下标
+
+	for _, r := range results {
+		r.Links = cleanLinks(r.Links)
+
+		target := -1
+		for _, link := range r.Links {
+			if i, ok := index[linkKey(link)]; ok {
+				target = i
+				break
+			}
+		}
+
+		if target < 0 {
+			r.Sources = appendSource(nil, r.Source)
+			merged = append(merged, r)
+			target = len(merged) - 1
+		} else {
+			mergeResult(&merged[target], r)
+		}
+
+		for _, link := range merged[target].Links {
+			key := linkKey(link)
+			if _, ok := index[key]; !ok {
+				index[key] = target
+			}
+		}
+	}
+
+	return merged
+}
+
+// mergeResult 将重复结果 src 合并到 dst
+func mergeResult(dst *model.SearchResult, src model.SearchResult) {
+	dst.Sources = appendSource(dst.Sources, src.Source)
+
+	for _, link := range src.Links {
+		key := linkKey(link)
+		found := false
+		for i := range dst.Links {
+			if linkKey(dst.Links[i]) != key {
+				continue
+			}
+			found = true
+			if dst.Links[i].Password == "" {
+				dst.Links[i].Password = link.Password
+			}
+			if dst.Links[i].Size == "" {
+				dst.Links[i].Size = link.Size
+			}
+			break
+		}
+		if !found {
+			dst.Links = append(dst.Links, link)
+		}
+	}
+
+	if dst.PublishTime.IsZero() && !src.PublishTime.IsZero() {
+		dst.PublishTime = src.PublishTime
+		dst.Datetime = src.Datetime
+	}
+	if dst.Description == "" {
+		dst.Description = src.Description
+	}
+}
+
+// appendSource 追加来源，已存在时忽略
+func appendSource(sources []string, source string) []string {
+	for _, s := range sources {
+		if s == source {
+			return sources
+		}
+	}
+	return append(sources, source)
+}
+
+// cleanLinks 去除链接中的跟踪参数，并从链接参数中补全提取码
+func cleanLinks(links []model.Link) []model.Link {
+	cleaned := make([]model.Link, len(links))
+	for i, link := range links {
+		cleaned[i] = cleanLink(link)
+	}
+	return cleaned
+}
+
+func cleanLink(link model.Link) model.Link {
+	if strings.HasPrefix(link.URL, "magnet:") {
+		return link
+	}
+
+	u, err := url.Parse(strings.TrimSpace(link.URL))
+	if err != nil || u.Host == "" {
+		return link
+	}
+
+	if link.Password == "" {
+		link.Password = links.URLPassword(link.URL)
+	}
+
+	query := u.Query()
+
+	for name := range query {
+		if trackingParams[strings.ToLower(name)] || strings.HasPrefix(strings.ToLower(name), "utm_") {
+			query.Del(name)
+		}
+	}
+	u.RawQuery = query.Encode()
+	u.Fragment = ""
+	link.URL = u.String()
+	return link
+}
+
+// linkKey 返回链接的规范化标识，同一资源的不同写法得到相同的值
+func linkKey(link model.Link) string {
+	raw := strings.TrimSpace(link.URL)
+	if strings.HasPrefix(strings.ToLower(raw), "magnet:") {
+		if hash := magnetInfoHash(raw); hash != "" {
+			return "magnet:" + hash
+		}
+		return strings.ToLower(raw)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if alias, ok := hostAliases[host]; ok {
+		host = alias
+	}
+	path := strings.TrimRight(u.Path, "/")
+
+	// 百度旧版分享链接 /share/init?surl=xxx 等价于 /s/1xxx
+	if host == "pan.baidu.com" && path == "/share/init" {
+		if surl := u.Query().Get("surl"); surl != "" {
+			return host + "/s/1" + surl
+		}
+	}
+
+	// 分享ID已能唯一确定资源，忽略其他参数
+	if strings.HasPrefix(path, "/s/") || strings.HasPrefix(path, "/t/") {
+		return host + path
+	}
+
+	query := u.Query()
+	for _, name := range passwordParams {
+		query.Del(name)
+	}
+	keys := make([]string, 0, len(query))
+	for name := range query {
+		lower := strings.ToLower(name)
+		if trackingParams[lower] || strings.HasPrefix(lower, "utm_") {
+			continue
+		}
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(host + path)
+	for i, name := range keys {
+		if i == 0 {
+			b.WriteString("?")
+		} else {
+			b.WriteString("&")
+		}
+		b.WriteString(name + "=" + query.Get(name))
+	}
+	return b.String()
+}
+
+// magnetInfoHash 提取磁力链接的infohash，统一为小写十六进制
+func magnetInfoHash(magnet string) string {
+	u, err := url.Parse(magnet)
+	if err != nil {
+		return ""
+	}
+	for _, xt := range u.Query()["xt"] {
+		const prefix = "urn:btih:"
+		if !strings.HasPrefix(strings.ToLower(xt), prefix) {
+			continue
+		}
+		hash := xt[len(prefix):]
+		switch len(hash) {
+		case 40:
+			return strings.ToLower(hash)
+		case 32:
+			// Base32编码的infohash
+			if b, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash)); err == nil {
+				return hex.EncodeToString(b)
+			}
+		}
+		return strings.ToLower(hash)
+	}
+	return ""
+}
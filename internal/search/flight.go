@@ -0,0 +1,99 @@
+package search
+
+import (
+	"context"
+	"sync"
+)
+
+// flightGroup 合并相同缓存键的并发搜索，同一时间每个键只有一次搜索在执行
+// 搜索使用独立的 context，所有等待者都退出（如客户端断开）后被取消；后台刷新发起或加入的搜索始终执行完成
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall 一次正在执行的搜索，结束后 done 被关闭
+// data 为编码后的搜索响应，每个等待者各自解码，互不影响
+type flightCall struct {
+	done chan struct{}
+	data []byte
+	err  error
+
+	key     string
+	cancel  context.CancelFunc
+	waiters int  // 仍在等待结果的调用方数量
+	pinned  bool // 后台刷新需要的搜索，等待者全部退出后也不取消
+}
+
+// Do 以等待者的身份加入相同键的搜索，没有时基于 parent 启动新的搜索
+// 调用方必须通过 Wait 获取结果；started 表示本次调用是否启动了新的搜索
+func (g *flightGroup) Do(parent context.Context, key string, fn func(ctx context.Context) ([]byte, error)) (call *flightCall, started bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	call, ok := g.calls[key]
+	if !ok {
+		call = g.start(parent, key, fn)
+	}
+	call.waiters++
+	return call, !ok
+}
+
+// Go 在后台执行相同键的搜索，已有搜索在执行时保证该搜索不会因等待者退出而被取消
+func (g *flightGroup) Go(parent context.Context, key string, fn func(ctx context.Context) ([]byte, error)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	call, ok := g.calls[key]
+	if !ok {
+		call = g.start(parent, key, fn)
+	}
+	call.pinned = true
+}
+
+// Wait 等待搜索结束，ctx 先结束时退出等待并返回 ctx 的错误
+// 最后一个等待者退出时取消未被后台刷新保留的搜索
+func (g *flightGroup) Wait(ctx context.Context, call *flightCall) ([]byte, error) {
+	select {
+	case <-call.done:
+		return call.data, call.err
+	case <-ctx.Done():
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	call.waiters--
+	if call.waiters == 0 && !call.pinned {
+		// 立即移除，之后的相同搜索不会加入已取消的搜索
+		if g.calls[call.key] == call {
+			delete(g.calls, call.key)
+		}
+		call.cancel()
+	}
+	return nil, ctx.Err()
+}
+
+// start 启动新的搜索，调用方需持有锁
+func (g *flightGroup) start(parent context.Context, key string, fn func(ctx context.Context) ([]byte, error)) *flightCall {
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	call := &flightCall{done: make(chan struct{}), key: key, cancel: cancel}
+	g.calls[key] = call
+
+	go func() {
+		defer cancel()
+		call.data, call.err = fn(ctx)
+
+		g.mu.Lock()
+		if g.calls[key] == call {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	return call
+}
@@ -0,0 +1,108 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blockingSearch 返回一直阻塞到 context 取消或 release 关闭的搜索函数
+func blockingSearch(release <-chan struct{}, cancelled chan<- struct{}) func(ctx context.Context) ([]byte, error) {
+	return func(ctx context.Context) ([]byte, error) {
+		select {
+		case <-release:
+			return []byte("ok"), nil
+		case <-ctx.Done():
+			close(cancelled)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func TestFlightCancelsWhenLastWaiterLeaves(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	cancelled := make(chan struct{})
+
+	call, started := g.Do(context.Background(), "k", blockingSearch(release, cancelled))
+	if !started {
+		t.Fatal("第一次调用应启动搜索")
+	}
+	if joined, started := g.Do(context.Background(), "k", nil); started || joined != call {
+		t.Fatal("相同键的调用应加入已有搜索")
+	}
+
+	// 第一个等待者退出，仍有等待者时搜索继续
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	cancel1()
+	if _, err := g.Wait(ctx1, call); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait: %v", err)
+	}
+	select {
+	case <-cancelled:
+		t.Fatal("还有等待者时搜索不应被取消")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// 最后一个等待者退出后搜索被取消
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	cancel2()
+	g.Wait(ctx2, call)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("所有等待者退出后搜索应被取消")
+	}
+
+	// 之后的相同搜索重新执行
+	release2 := make(chan struct{})
+	close(release2)
+	next, started := g.Do(context.Background(), "k", blockingSearch(release2, make(chan struct{})))
+	if !started || next == call {
+		t.Fatal("已取消的搜索不应被复用")
+	}
+	if data, err := g.Wait(context.Background(), next); err != nil || string(data) != "ok" {
+		t.Fatalf("Wait: %q %v", data, err)
+	}
+}
+
+func TestFlightPinnedSearchSurvivesWaiters(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	cancelled := make(chan struct{})
+
+	call, _ := g.Do(context.Background(), "k", blockingSearch(release, cancelled))
+	g.Go(context.Background(), "k", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g.Wait(ctx, call)
+
+	select {
+	case <-cancelled:
+		t.Fatal("后台刷新保留的搜索不应被取消")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	<-call.done
+	if call.err != nil || string(call.data) != "ok" {
+		t.Fatalf("搜索结果: %q %v", call.data, call.err)
+	}
+}
+
+func TestFlightParentCancel(t *testing.T) {
+	var g flightGroup
+	parent, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan struct{})
+
+	g.Go(parent, "k", blockingSearch(make(chan struct{}), cancelled))
+	cancel()
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("服务关闭时后台刷新应被取消")
+	}
+}
@@ -0,0 +1,115 @@
+package search
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"pansou-openwrt/internal/linkcheck"
+	"pansou-openwrt/internal/model"
+)
+
+// linkCheckTimeout 单个网盘接口请求的超时时间
+const linkCheckTimeout = 8 * time.Second
+
+// checkLinks 按请求的 validate_links 检测结果中的链接（为空时使用配置中的默认值）
+// flag 在支持检测的链接上标记 status；drop 同时移除已失效的链接和没有剩余链接的结果
+// 检测受 Search.Timeout 限制，超时未完成的链接记为 unknown
+// 分页时只检测当前页，Total 和 TypeTotals 减去当前页中移除的结果数
+func (s *Service) checkLinks(ctx context.Context, req *model.SearchRequest, resp *model.SearchResponse) {
+	cfg := s.cfg()
+	mode := req.ValidateLinks
+	if mode == "" {
+		mode = cfg.Search.ValidateLinks
+	}
+	if mode != linkcheck.ModeFlag && mode != linkcheck.ModeDrop {
+		return
+	}
+
+	links := make([]model.Link, 0)
+	for _, r := range resp.Results {
+		links = append(links, r.Links...)
+	}
+	for _, bucket := range resp.MergedByType {
+		for _, r := range bucket {
+			links = append(links, r.Links...)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Search.Timeout)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	statuses := s.checker.CheckAll(ctx, links)
+	if len(statuses) > 0 {
+		log.Printf("[链接检测] 检测了 %d 个链接，耗时 %.2fs", len(statuses), time.Since(start).Seconds())
+	}
+
+	// 移除的结果从 Total 和 TypeTotals 中扣除，分页时其余页未检测，仍按原数计算
+	drop := mode == linkcheck.ModeDrop
+	removed := 0
+	if resp.Results != nil {
+		before := len(resp.Results)
+		resp.Results = applyStatuses(resp.Results, statuses, drop)
+		removed = before - len(resp.Results)
+	}
+	for typ, bucket := range resp.MergedByType {
+		before := len(bucket)
+		bucket = applyStatuses(bucket, statuses, drop)
+		if n := before - len(bucket); n > 0 {
+			if resp.Results == nil {
+				removed += n
+			}
+			if resp.TypeTotals != nil {
+				resp.TypeTotals[typ] -= n
+			}
+		}
+		if len(bucket) == 0 {
+			delete(resp.MergedByType, typ)
+		} else {
+			resp.MergedByType[typ] = bucket
+		}
+	}
+	resp.Total -= removed
+}
+
+// applyStatuses 在链接上标记检测结果，drop 为true时移除已失效的链接和没有剩余链接的结果
+func applyStatuses(results []model.SearchResult, statuses map[string]linkcheck.Status, drop bool) []model.SearchResult {
+	kept := results[:0]
+	for _, r := range results {
+		links := make([]model.Link, 0, len(r.Links))
+		for _, link := range r.Links {
+			status, ok := statuses[linkcheck.Key(link)]
+			if !ok {
+				links = append(links, link)
+				continue
+			}
+			if drop && status == linkcheck.StatusExpired {
+				continue
+			}
+			link.Status = string(status)
+			links = append(links, link)
+		}
+		if len(links) == 0 && len(r.Links) > 0 {
+			continue
+		}
+		r.Links = links
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// countResults 统计按类型合并的结果中不同结果的数量，同一结果可能出现在多个类型中
+func countResults(merged map[string][]model.SearchResult) int {
+	seen := make(map[string]bool)
+	for _, bucket := range merged {
+		for _, r := range bucket {
+			key := r.UniqueID
+			if key == "" {
+				key = r.Source + "\x00" + r.Title
+			}
+			seen[key] = true
+		}
+	}
+	return len(seen)
+}
@@ -0,0 +1,149 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"sort"
+
+	"pansou-openwrt/internal/model"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var (
+	// ErrInvalidCursor 翻页游标无法解析
+	ErrInvalidCursor = errors.New("无效的翻页游标")
+	// ErrCursorExpired 签发游标后搜索结果已更新（缓存刷新或过期后重新搜索），需要从第一页重新获取
+	ErrCursorExpired = errors.New("搜索结果已更新，请从第一页重新获取")
+)
+
+// pageCursor 翻页游标的内容：下一页的完整请求和签发游标时结果集的版本
+type pageCursor struct {
+	Request model.SearchRequest `json:"q"`
+	Version uint32              `json:"v"`
+}
+
+// resolveCursor 解析请求中的翻页游标，返回游标中保存的请求和结果集版本
+// 没有游标时原样返回请求，版本为0
+func resolveCursor(req *model.SearchRequest) (*model.SearchRequest, uint32, error) {
+	if req.Cursor == "" {
+		return req, 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(req.Cursor)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Request.Keyword == "" || cursor.Request.Page < 1 {
+		return nil, 0, ErrInvalidCursor
+	}
+	return &cursor.Request, cursor.Version, nil
+}
+
+// encodeCursor 生成请求下一页的游标
+func encodeCursor(req *model.SearchRequest, version uint32) string {
+	next := *req
+	next.Page++
+	next.Cursor = ""
+	next.ForceRefresh = false
+
+	data, err := json.Marshal(pageCursor{Request: next, Version: version})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// resultVersion 计算结果集的版本，相同的结果和顺序得到相同的值
+func resultVersion(resp *model.SearchResponse) uint32 {
+	h := fnv.New32a()
+	write := func(results []model.SearchResult) {
+		for _, r := range results {
+			h.Write([]byte(r.UniqueID))
+			for _, link := range r.Links {
+				h.Write([]byte{0})
+				h.Write([]byte(link.URL))
+			}
+			h.Write([]byte{1})
+		}
+	}
+
+	write(resp.Results)
+	types := make([]string, 0, len(resp.MergedByType))
+	for typ := range resp.MergedByType {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		h.Write([]byte(typ))
+		write(resp.MergedByType[typ])
+	}
+	return h.Sum32()
+}
+
+// paginate 按请求的页码截取结果，还有下一页时生成 next_cursor
+// results 按 page_size 分页；merged_by_type 中每种类型按 type_limit（未指定时为 page_size）分别分页
+// 只指定 type_limit 时只截取每种类型的前 type_limit 条，不生成游标
+// Total 保持截取前的结果总数
+func paginate(req *model.SearchRequest, resp *model.SearchResponse, version uint32) {
+	paged := req.Page > 0 || req.PageSize > 0
+	if !paged && req.TypeLimit <= 0 {
+		return
+	}
+
+	page := max(req.Page, 1)
+	size := pageSize(req.PageSize, defaultPageSize)
+	typeSize := pageSize(req.TypeLimit, size)
+	if !paged {
+		page = 1
+		size = 0
+	}
+
+	more := false
+	if paged && resp.Results != nil {
+		var m bool
+		resp.Results, m = pageSlice(resp.Results, (page-1)*size, size)
+		more = more || m
+	}
+	if len(resp.MergedByType) > 0 {
+		resp.TypeTotals = make(map[string]int, len(resp.MergedByType))
+		for typ, bucket := range resp.MergedByType {
+			resp.TypeTotals[typ] = len(bucket)
+			var m bool
+			resp.MergedByType[typ], m = pageSlice(bucket, (page-1)*typeSize, typeSize)
+			more = more || m
+		}
+	}
+
+	resp.Page = page
+	resp.PageSize = size
+	if paged && more {
+		r := *req
+		r.Page = page
+		r.PageSize = size
+		resp.NextCursor = encodeCursor(&r, version)
+	}
+}
+
+// pageSize 规范化每页条数：未指定时使用 def，超过 maxPageSize 时截断
+func pageSize(size, def int) int {
+	if size <= 0 {
+		size = def
+	}
+	return min(size, maxPageSize)
+}
+
+// pageSlice 截取 [offset, offset+size)，返回截取的部分和之后是否还有结果
+func pageSlice(results []model.SearchResult, offset, size int) ([]model.SearchResult, bool) {
+	if offset >= len(results) {
+		return []model.SearchResult{}, false
+	}
+	end := min(offset+size, len(results))
+	return results[offset:end], end < len(results)
+}
@@ -0,0 +1,278 @@
+package search
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pansou-openwrt/internal/linkcheck"
+	"pansou-openwrt/internal/model"
+)
+
+// pagedResponse 返回 n 条夸克结果和 m 条百度结果，Results 包含全部结果
+func pagedResponse(n, m int) *model.SearchResponse {
+	resp := &model.SearchResponse{MergedByType: make(map[string][]model.SearchResult)}
+	add := func(typ, host string, count int) {
+		for i := 0; i < count; i++ {
+			r := model.SearchResult{
+				UniqueID: fmt.Sprintf("%s%d", typ, i),
+				Title:    "三体",
+				Links:    []model.Link{{Type: typ, URL: fmt.Sprintf("https://%s/s/%s%d", host, typ, i)}},
+			}
+			resp.Results = append(resp.Results, r)
+			resp.MergedByType[typ] = append(resp.MergedByType[typ], r)
+		}
+	}
+	add("quark", "pan.quark.cn", n)
+	add("baidu", "pan.baidu.com", m)
+	resp.Total = n + m
+	return resp
+}
+
+func ids(results []model.SearchResult) []string {
+	ids := make([]string, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.UniqueID)
+	}
+	return ids
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        model.SearchRequest
+		results    []string
+		quark      []string
+		page, size int
+		cursor     bool
+	}{
+		{
+			name:    "不分页",
+			req:     model.SearchRequest{},
+			results: []string{"quark0", "quark1", "quark2", "baidu0", "baidu1"},
+			quark:   []string{"quark0", "quark1", "quark2"},
+		},
+		{
+			name:    "第一页",
+			req:     model.SearchRequest{Page: 1, PageSize: 2},
+			results: []string{"quark0", "quark1"},
+			quark:   []string{"quark0", "quark1"},
+			page:    1, size: 2, cursor: true,
+		},
+		{
+			name:    "只指定每页条数",
+			req:     model.SearchRequest{PageSize: 4},
+			results: []string{"quark0", "quark1", "quark2", "baidu0"},
+			quark:   []string{"quark0", "quark1", "quark2"},
+			page:    1, size: 4, cursor: true,
+		},
+		{
+			name:    "最后一页",
+			req:     model.SearchRequest{Page: 3, PageSize: 2},
+			results: []string{"baidu1"},
+			quark:   []string{},
+			page:    3, size: 2,
+		},
+		{
+			name:    "超出范围",
+			req:     model.SearchRequest{Page: 9, PageSize: 2},
+			results: []string{},
+			quark:   []string{},
+			page:    9, size: 2,
+		},
+		{
+			name:    "每种类型单独的条数",
+			req:     model.SearchRequest{Page: 1, PageSize: 5, TypeLimit: 1},
+			results: []string{"quark0", "quark1", "quark2", "baidu0", "baidu1"},
+			quark:   []string{"quark0"},
+			page:    1, size: 5, cursor: true,
+		},
+		{
+			name:    "只指定type_limit时不生成游标",
+			req:     model.SearchRequest{TypeLimit: 2},
+			results: []string{"quark0", "quark1", "quark2", "baidu0", "baidu1"},
+			quark:   []string{"quark0", "quark1"},
+			page:    1,
+		},
+		{
+			name:    "每页条数上限",
+			req:     model.SearchRequest{Page: 1, PageSize: 1000},
+			results: []string{"quark0", "quark1", "quark2", "baidu0", "baidu1"},
+			quark:   []string{"quark0", "quark1", "quark2"},
+			page:    1, size: maxPageSize,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := pagedResponse(3, 2)
+			paginate(&tt.req, resp, 1)
+
+			if got := ids(resp.Results); !reflect.DeepEqual(got, tt.results) {
+				t.Errorf("results = %v, want %v", got, tt.results)
+			}
+			if got := ids(resp.MergedByType["quark"]); !reflect.DeepEqual(got, tt.quark) {
+				t.Errorf("quark = %v, want %v", got, tt.quark)
+			}
+			if resp.Page != tt.page || resp.PageSize != tt.size {
+				t.Errorf("page = %d, page_size = %d, want %d, %d", resp.Page, resp.PageSize, tt.page, tt.size)
+			}
+			if (resp.NextCursor != "") != tt.cursor {
+				t.Errorf("next_cursor = %q", resp.NextCursor)
+			}
+			if resp.Total != 5 {
+				t.Errorf("Total = %d，分页不改变总数", resp.Total)
+			}
+			if tt.page > 0 && (resp.TypeTotals["quark"] != 3 || resp.TypeTotals["baidu"] != 2) {
+				t.Errorf("type_totals = %v", resp.TypeTotals)
+			}
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	req := &model.SearchRequest{
+		Keyword:      "三体",
+		Page:         2,
+		PageSize:     10,
+		TypeLimit:    3,
+		CloudTypes:   []string{"quark"},
+		ForceRefresh: true,
+	}
+	cursor := encodeCursor(req, 42)
+
+	next, version, err := resolveCursor(&model.SearchRequest{Cursor: cursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := *req
+	want.Page = 3
+	want.ForceRefresh = false
+	if !reflect.DeepEqual(*next, want) {
+		t.Errorf("游标中的请求 = %+v, want %+v", *next, want)
+	}
+	if version != 42 {
+		t.Errorf("version = %d, want 42", version)
+	}
+
+	// 没有游标时原样返回请求
+	plain := &model.SearchRequest{Keyword: "三体"}
+	if got, version, err := resolveCursor(plain); err != nil || got != plain || version != 0 {
+		t.Errorf("resolveCursor = %v, %d, %v", got, version, err)
+	}
+}
+
+func TestResolveCursorInvalid(t *testing.T) {
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	for _, cursor := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		encode(pageCursor{Request: model.SearchRequest{Page: 2}}),
+		encode(pageCursor{Request: model.SearchRequest{Keyword: "三体"}}),
+	} {
+		if _, _, err := resolveCursor(&model.SearchRequest{Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("resolveCursor(%q) error = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
+
+func TestSearchCursorExpired(t *testing.T) {
+	s := newTestService(t)
+	req := &model.SearchRequest{Keyword: "三体", ResultType: "all"}
+	key := buildCacheKey(req)
+	putCached(t, s, key, pagedResponse(3, 2))
+
+	first, err := s.Search(context.Background(), &model.SearchRequest{Keyword: "三体", ResultType: "all", Page: 1, PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.NextCursor == "" {
+		t.Fatal("第一页应返回 next_cursor")
+	}
+
+	second, err := s.Search(context.Background(), &model.SearchRequest{Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(second.Results); !reflect.DeepEqual(got, []string{"quark2", "baidu0"}) || second.Page != 2 {
+		t.Errorf("第二页 = %v (page %d)", got, second.Page)
+	}
+
+	// 结果集更新后旧游标失效
+	putCached(t, s, key, pagedResponse(4, 2))
+	if _, err := s.Search(context.Background(), &model.SearchRequest{Cursor: first.NextCursor}); !errors.Is(err, ErrCursorExpired) {
+		t.Errorf("error = %v, want ErrCursorExpired", err)
+	}
+}
+
+// deadQuark 模拟夸克分享接口，id 以 dead 开头的分享已失效
+func deadQuark(t *testing.T) linkcheck.Endpoints {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ID string `json:"pwd_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		code := 0
+		if strings.HasPrefix(body.ID, "dead") {
+			code = 41004
+		}
+		json.NewEncoder(w).Encode(map[string]int{"code": code})
+	}))
+	t.Cleanup(srv.Close)
+	return linkcheck.Endpoints{Quark: srv.URL}
+}
+
+func TestCheckLinksDropUpdatesTotal(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        model.SearchRequest
+		total      int
+		quarkTotal int
+	}{
+		{"不分页", model.SearchRequest{}, 4, 0},
+		{"只指定type_limit", model.SearchRequest{TypeLimit: 2}, 4, 2},
+		// 第一页只包含 dead0，其余页未检测
+		{"分页", model.SearchRequest{Page: 1, PageSize: 1}, 4, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t)
+			s.checker = linkcheck.New(http.DefaultClient, deadQuark(t))
+
+			resp := pagedResponse(3, 2)
+			resp.MergedByType["quark"][0].Links[0].URL = "https://pan.quark.cn/s/dead0"
+			resp.Results[0].Links[0].URL = "https://pan.quark.cn/s/dead0"
+
+			req := tt.req
+			req.ValidateLinks = linkcheck.ModeDrop
+			paginate(&req, resp, 1)
+			s.checkLinks(context.Background(), &req, resp)
+
+			if resp.Total != tt.total {
+				t.Errorf("Total = %d, want %d", resp.Total, tt.total)
+			}
+			for _, r := range resp.Results {
+				if r.UniqueID == "quark0" {
+					t.Error("失效链接的结果应被移除")
+				}
+			}
+			if tt.quarkTotal > 0 && resp.TypeTotals["quark"] != tt.quarkTotal {
+				t.Errorf("type_totals = %v, want quark %d", resp.TypeTotals, tt.quarkTotal)
+			}
+		})
+	}
+}
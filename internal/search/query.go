@@ -0,0 +1,331 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"pansou-openwrt/internal/model"
+)
+
+// ErrInvalidQuery 搜索语句中的过滤条件无法解析
+var ErrInvalidQuery = errors.New("无效的搜索语句")
+
+// advancedQuery 解析后的搜索语句
+// 普通词和引号中的短语组成发送给搜索源的关键词，其余条件在合并后的结果上本地过滤：
+//
+//	"三体"            标题或描述必须包含完整短语
+//	-预告 -"预告片"   标题和描述不能包含的词或短语
+//	type:quark,baidu 只保留这些类型的链接（未识别的类型用 others）
+//	after:2024-01-01 before:2024-12-31  发布时间范围，没有发布时间的结果被过滤
+//	source:xys source:tg source:tg:频道名  只保留这些来源的结果
+//	has:password -has:password  只保留有（或没有）提取码的链接
+type advancedQuery struct {
+	keyword     string   // 发送给搜索源的关键词
+	phrases     []string // 必须包含的短语（小写）
+	excludes    []string // 不能包含的词（小写）
+	types       []string
+	sources     []string
+	after       time.Time
+	before      time.Time // 不含当天之后的时间，已换算为次日零点
+	hasPassword *bool
+}
+
+// queryDateLayouts 日期过滤支持的格式
+var queryDateLayouts = []string{"2006-01-02", "2006/01/02", "20060102"}
+
+// parseQuery 解析搜索语句，关键词为空（只有过滤条件）时返回错误
+func parseQuery(text string) (*advancedQuery, error) {
+	q := &advancedQuery{}
+	terms := make([]string, 0)
+
+	for _, tok := range splitQuery(text) {
+		switch {
+		case tok.negated && tok.quoted:
+			q.excludes = append(q.excludes, strings.ToLower(tok.text))
+		case tok.quoted:
+			q.phrases = append(q.phrases, strings.ToLower(tok.text))
+			terms = append(terms, tok.text)
+		default:
+			ok, err := q.applyOperator(tok)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				continue
+			}
+			if tok.negated {
+				q.excludes = append(q.excludes, strings.ToLower(tok.text))
+			} else {
+				terms = append(terms, tok.text)
+			}
+		}
+	}
+
+	q.keyword = strings.Join(terms, " ")
+	if q.keyword == "" {
+		return nil, fmt.Errorf("%w: 缺少搜索关键词", ErrInvalidQuery)
+	}
+	return q, nil
+}
+
+// applyOperator 处理 name:value 形式的过滤条件，不是已知的过滤条件时返回false（作为普通词处理）
+func (q *advancedQuery) applyOperator(tok queryToken) (bool, error) {
+	name, value, ok := strings.Cut(tok.text, ":")
+	if !ok || value == "" {
+		return false, nil
+	}
+
+	name = strings.ToLower(name)
+	switch name {
+	case "type", "source", "after", "before":
+		if tok.negated {
+			return false, fmt.Errorf("%w: 不支持排除 %s", ErrInvalidQuery, tok.text)
+		}
+	}
+
+	switch name {
+	case "type":
+		q.types = append(q.types, splitValues(value)...)
+	case "source":
+		q.sources = append(q.sources, splitValues(value)...)
+	case "after", "before":
+		t, err := parseQueryDate(value)
+		if err != nil {
+			return false, fmt.Errorf("%w: %s", ErrInvalidQuery, tok.text)
+		}
+		if name == "after" {
+			q.after = t
+		} else {
+			q.before = t.AddDate(0, 0, 1)
+		}
+	case "has":
+		if !strings.EqualFold(value, "password") {
+			return false, fmt.Errorf("%w: %s", ErrInvalidQuery, tok.text)
+		}
+		has := !tok.negated
+		q.hasPassword = &has
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// parseQueryDate 解析日期（本地时区零点）
+func parseQueryDate(value string) (time.Time, error) {
+	for _, layout := range queryDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无效的日期: %s", value)
+}
+
+// splitValues 拆分逗号分隔的取值
+func splitValues(value string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, strings.ToLower(v))
+		}
+	}
+	return values
+}
+
+// queryToken 搜索语句中的一个词
+type queryToken struct {
+	text    string
+	quoted  bool // 引号中的短语
+	negated bool // 以 - 开头
+}
+
+// splitQuery 按空白拆分搜索语句，引号（"" 或中文引号）中的内容作为一个整体
+// 单独的 - 或引号未闭合时按普通文本处理
+func splitQuery(text string) []queryToken {
+	tokens := make([]queryToken, 0)
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		tok := queryToken{}
+		start := i
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			tok.negated = true
+			i++
+		}
+
+		if closing, ok := closingQuote(runes[i]); ok {
+			if end := indexRune(runes, closing, i+1); end > i+1 {
+				tok.text = strings.TrimSpace(string(runes[i+1 : end]))
+				tok.quoted = true
+				i = end + 1
+				if tok.text != "" {
+					tokens = append(tokens, tok)
+				}
+				continue
+			}
+		}
+
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+		tok.text = string(runes[start:i])
+		if tok.negated {
+			tok.text = tok.text[1:]
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+// closingQuote 返回开引号对应的闭引号
+func closingQuote(r rune) (rune, bool) {
+	switch r {
+	case '"':
+		return '"', true
+	case '“':
+		return '”', true
+	}
+	return 0, false
+}
+
+// indexRune 从 from 开始查找字符，找不到时返回-1
+func indexRune(runes []rune, r rune, from int) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// hasFilters 判断是否有需要本地过滤的条件
+func (q *advancedQuery) hasFilters() bool {
+	return len(q.phrases) > 0 || len(q.excludes) > 0 || len(q.types) > 0 || len(q.sources) > 0 ||
+		!q.after.IsZero() || !q.before.IsZero() || q.hasPassword != nil
+}
+
+// apply 过滤完整的搜索响应，并重新计算 Total
+func (q *advancedQuery) apply(resp *model.SearchResponse) {
+	if !q.hasFilters() {
+		return
+	}
+
+	if resp.Results != nil {
+		resp.Results = q.filter(resp.Results)
+	}
+	for typ, bucket := range resp.MergedByType {
+		bucket = q.filter(bucket)
+		if len(bucket) == 0 {
+			delete(resp.MergedByType, typ)
+		} else {
+			resp.MergedByType[typ] = bucket
+		}
+	}
+
+	if resp.Results != nil {
+		resp.Total = len(resp.Results)
+	} else {
+		resp.Total = countResults(resp.MergedByType)
+	}
+}
+
+// filter 返回满足所有过滤条件的结果，结果中只保留满足类型和提取码条件的链接
+func (q *advancedQuery) filter(results []model.SearchResult) []model.SearchResult {
+	if !q.hasFilters() {
+		return results
+	}
+
+	kept := make([]model.SearchResult, 0, len(results))
+	for _, r := range results {
+		if !q.matchResult(&r) {
+			continue
+		}
+		links := make([]model.Link, 0, len(r.Links))
+		for _, link := range r.Links {
+			if q.matchLink(link) {
+				links = append(links, link)
+			}
+		}
+		if len(links) == 0 {
+			continue
+		}
+		r.Links = links
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// matchResult 检查短语、排除词、发布时间和来源
+func (q *advancedQuery) matchResult(r *model.SearchResult) bool {
+	text := strings.ToLower(r.Title + "\n" + r.Description)
+	for _, phrase := range q.phrases {
+		if !strings.Contains(text, phrase) {
+			return false
+		}
+	}
+	for _, word := range q.excludes {
+		if strings.Contains(text, word) {
+			return false
+		}
+	}
+
+	if !q.after.IsZero() || !q.before.IsZero() {
+		if r.PublishTime.IsZero() ||
+			(!q.after.IsZero() && r.PublishTime.Before(q.after)) ||
+			(!q.before.IsZero() && !r.PublishTime.Before(q.before)) {
+			return false
+		}
+	}
+
+	if len(q.sources) > 0 {
+		matched := matchSource(r.Source, q.sources)
+		for _, source := range r.Sources {
+			matched = matched || matchSource(source, q.sources)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLink 检查链接类型和提取码
+func (q *advancedQuery) matchLink(link model.Link) bool {
+	if len(q.types) > 0 && !contains(q.types, mergeType(link.Type)) && !contains(q.types, link.Type) {
+		return false
+	}
+	if q.hasPassword != nil && (link.Password != "") != *q.hasPassword {
+		return false
+	}
+	return true
+}
+
+// matchSource 判断来源（plugin:xxx 或 tg:channel）是否匹配过滤值
+// 过滤值可以是完整来源、插件名、频道名，或 plugin / tg 表示所有插件或所有频道
+func matchSource(source string, values []string) bool {
+	source = strings.ToLower(source)
+	kind, name, _ := strings.Cut(source, ":")
+	for _, v := range values {
+		if v == source || v == kind || v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// contains 判断列表中是否包含指定值
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"pansou-openwrt/internal/model"
+)
+
+func date(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseQuery(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		text string
+		want advancedQuery
+	}{
+		{"三体", advancedQuery{keyword: "三体"}},
+		{"  三体   全集 ", advancedQuery{keyword: "三体 全集"}},
+		{`"三体 第一季" 4K`, advancedQuery{keyword: "三体 第一季 4K", phrases: []string{"三体 第一季"}}},
+		{"“流浪地球” 2023", advancedQuery{keyword: "流浪地球 2023", phrases: []string{"流浪地球"}}},
+		{`三体 -预告 -"先导 片花"`, advancedQuery{keyword: "三体", excludes: []string{"预告", "先导 片花"}}},
+		{"三体 - 全集", advancedQuery{keyword: "三体 - 全集"}},
+		{`三体 "未闭合`, advancedQuery{keyword: `三体 "未闭合`}},
+		{"三体 type:Quark,baidu type:123", advancedQuery{keyword: "三体", types: []string{"quark", "baidu", "123"}}},
+		{"三体 source:xys,tg:Channel", advancedQuery{keyword: "三体", sources: []string{"xys", "tg:channel"}}},
+		{"三体 after:2024-01-01 before:2024/12/31", advancedQuery{
+			keyword: "三体", after: date("2024-01-01"), before: date("2025-01-01"),
+		}},
+		{"三体 after:20240301", advancedQuery{keyword: "三体", after: date("2024-03-01")}},
+		{"三体 has:password", advancedQuery{keyword: "三体", hasPassword: &yes}},
+		{"三体 -has:PASSWORD", advancedQuery{keyword: "三体", hasPassword: &no}},
+		// 未知的过滤条件和没有取值的条件作为普通词
+		{"三体 season:1 type:", advancedQuery{keyword: "三体 season:1 type:"}},
+		{"三体 -season:1", advancedQuery{keyword: "三体", excludes: []string{"season:1"}}},
+		{"https://example.com/三体", advancedQuery{keyword: "https://example.com/三体"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := parseQuery(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("parseQuery(%q)\n got: %+v\nwant: %+v", tt.text, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"   ",
+		"type:baidu",
+		"-三体",
+		`-"三体"`,
+		"has:password source:xys",
+		"三体 after:2024-13-01",
+		"三体 before:yesterday",
+		"三体 has:link",
+		"三体 -type:baidu",
+		"三体 -source:xys",
+		"三体 -after:2024-01-01",
+	} {
+		if _, err := parseQuery(text); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("parseQuery(%q) error = %v, want ErrInvalidQuery", text, err)
+		}
+	}
+}
+
+func TestQueryFilter(t *testing.T) {
+	results := []model.SearchResult{
+		{
+			UniqueID: "1", Title: "三体 全集 4K", Source: "plugin:xys",
+			PublishTime: date("2024-03-01"),
+			Links: []model.Link{
+				{Type: "quark", URL: "https://pan.quark.cn/s/a"},
+				{Type: "baidu", URL: "https://pan.baidu.com/s/1b", Password: "abcd"},
+			},
+		},
+		{
+			UniqueID: "2", Title: "三体 预告片", Source: "tg:MovieChannel",
+			PublishTime: date("2024-06-01"),
+			Links:       []model.Link{{Type: "aliyun", URL: "https://www.alipan.com/s/c"}},
+		},
+		{
+			UniqueID: "3", Title: "三体 广播剧", Description: "第一季 完结", Source: "plugin:labi",
+			Sources: []string{"plugin:labi", "tg:other"},
+			Links:   []model.Link{{Type: "lanzou", URL: "https://wwi.lanzoup.com/abc"}},
+		},
+	}
+
+	tests := []struct {
+		query string
+		ids   []string
+		links int // 保留的链接总数
+	}{
+		{"三体", []string{"1", "2", "3"}, 4},
+		{"三体 -预告", []string{"1", "3"}, 3},
+		{`三体 "第一季"`, []string{"3"}, 1},
+		{"三体 type:baidu", []string{"1"}, 1},
+		{"三体 type:lanzou", []string{"3"}, 1},
+		{"三体 has:password", []string{"1"}, 1},
+		{"三体 -has:password", []string{"1", "2", "3"}, 3},
+		{"三体 source:xys", []string{"1"}, 2},
+		{"三体 source:tg", []string{"2", "3"}, 2},
+		{"三体 source:moviechannel", []string{"2"}, 1},
+		{"三体 source:tg:other", []string{"3"}, 1},
+		// 没有发布时间的结果在按时间过滤时被排除
+		{"三体 after:2024-04-01", []string{"2"}, 1},
+		{"三体 before:2024-03-01", []string{"1"}, 2},
+		{"三体 after:2024-03-02 before:2024-05-31", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := parseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filtered := q.filter(append([]model.SearchResult(nil), results...))
+			ids := make([]string, 0, len(filtered))
+			links := 0
+			for _, r := range filtered {
+				ids = append(ids, r.UniqueID)
+				links += len(r.Links)
+			}
+			if len(ids) != len(tt.ids) || (len(ids) > 0 && !reflect.DeepEqual(ids, tt.ids)) {
+				t.Errorf("结果 = %v, want %v", ids, tt.ids)
+			}
+			if links != tt.links {
+				t.Errorf("链接数 = %d, want %d", links, tt.links)
+			}
+		})
+	}
+
+	// 过滤不修改原结果中的链接
+	if len(results[0].Links) != 2 {
+		t.Error("filter 修改了原结果")
+	}
+}
+
+func TestQueryApplyRecomputesTotal(t *testing.T) {
+	resp := testResponse()
+	resp.Results = append(resp.Results, model.SearchResult{
+		UniqueID: "tg:a:2", Title: "三体 预告",
+		Links: []model.Link{{Type: "baidu", URL: "https://pan.baidu.com/s/1x"}},
+	})
+	resp.MergedByType["baidu"] = resp.Results[1:]
+	resp.Total = 2
+
+	q, _ := parseQuery("三体 -预告")
+	q.apply(resp)
+	if resp.Total != 1 || len(resp.Results) != 1 {
+		t.Errorf("Total = %d, results = %d", resp.Total, len(resp.Results))
+	}
+	if _, ok := resp.MergedByType["baidu"]; ok {
+		t.Error("过滤后为空的类型应被删除")
+	}
+}
+
+func TestSearchSendsOnlyKeywordUpstream(t *testing.T) {
+	s := newTestService(t)
+
+	// 只有过滤条件时不发起搜索
+	for _, text := range []string{"type:baidu", "-预告 has:password"} {
+		_, err := s.Search(context.Background(), &model.SearchRequest{Keyword: text})
+		if !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("Search(%q) error = %v, want ErrInvalidQuery", text, err)
+		}
+	}
+
+	// 过滤条件不同的搜索共用只含关键词的缓存
+	putCached(t, s, buildCacheKey(&model.SearchRequest{Keyword: "三体 全集", ResultType: "all"}), testResponse())
+	for _, text := range []string{`三体 全集 type:quark`, `"三体" 全集 -预告 source:tg`} {
+		resp, err := s.Search(context.Background(), &model.SearchRequest{Keyword: text, ResultType: "all"})
+		if err != nil {
+			t.Fatalf("Search(%q): %v", text, err)
+		}
+		if !resp.CacheHit || resp.Total != 1 {
+			t.Errorf("Search(%q): cache_hit = %v, total = %d", text, resp.CacheHit, resp.Total)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"pansou-openwrt/internal/model"
+)
+
+// 相关性评分权重
+const (
+	scoreExactTitle    = 100.0 // 标题与关键词完全一致
+	scorePhraseTitle   = 60.0  // 标题包含完整关键词
+	scoreTitlePrefix   = 10.0  // 标题以关键词开头
+	scoreTokenTitle    = 40.0  // 关键词分词在标题中的覆盖率
+	scoreBigramTitle   = 30.0  // 中文双字切分在标题中的覆盖率
+	scorePhraseDesc    = 10.0  // 描述包含完整关键词
+	scoreTokenDesc     = 10.0  // 关键词分词在描述中的覆盖率
+	scoreRecency       = 20.0  // 发布时间新鲜度
+	recencyHalfLife    = 30.0  // 新鲜度衰减到一半所需天数
+	scoreSource        = 10.0  // 搜索源优先级（除以优先级值）
+	scorePerLink       = 2.0   // 每个链接的加分
+	maxScoredLinkCount = 3     // 参与加分的最大链接数
+)
+
+// query 预处理后的搜索关键词
+type query struct {
+	phrase  string   // 去除空白和标点后的小写关键词
+	tokens  []string // 按空白和标点切分的词
+	bigrams []string // 中文连续字符的双字切分
+}
+
+func newQuery(keyword string) query {
+	q := query{
+		phrase: normalizeText(keyword),
+		tokens: tokenize(keyword),
+	}
+	for _, token := range q.tokens {
+		q.bigrams = append(q.bigrams, bigrams(token)...)
+	}
+	return q
+}
+
+// rankResults 计算每条结果的相关性得分并按得分降序排序，得分相同的保持原有顺序
+func rankResults(results []model.SearchResult, keyword string, priorities map[string]int) {
+	q := newQuery(keyword)
+	now := time.Now()
+	for i := range results {
+		results[i].Score = scoreResult(&results[i], q, sourcePriority(&results[i], priorities), now)
+	}
+	sortByScore(results)
+}
+
+// sourcePriority 返回结果所有来源中最高的优先级（数值最小）
+func sourcePriority(r *model.SearchResult, priorities map[string]int) int {
+	best := priorities[r.Source]
+	for _, source := range r.Sources {
+		if p := priorities[source]; p > 0 && (best == 0 || p < best) {
+			best = p
+		}
+	}
+	return best
+}
+
+// sortByScore 按得分降序稳定排序
+func sortByScore(results []model.SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+}
+
+// scoreResult 计算单条结果的相关性得分
+func scoreResult(r *model.SearchResult, q query, priority int, now time.Time) float64 {
+	score := 0.0
+
+	// 标题匹配
+	title := normalizeText(r.Title)
+	if q.phrase != "" {
+		switch {
+		case title == q.phrase:
+			score += scoreExactTitle
+		case strings.Contains(title, q.phrase):
+			score += scorePhraseTitle
+			if strings.HasPrefix(title, q.phrase) {
+				score += scoreTitlePrefix
+			}
+		}
+	}
+	score += scoreTokenTitle * coverage(title, q.tokens)
+	score += scoreBigramTitle * coverage(title, q.bigrams)
+
+	// 描述匹配
+	if desc := normalizeText(r.Description); desc != "" {
+		if q.phrase != "" && strings.Contains(desc, q.phrase) {
+			score += scorePhraseDesc
+		}
+		score += scoreTokenDesc * coverage(desc, q.tokens)
+	}
+
+	// 发布时间越新得分越高
+	if !r.PublishTime.IsZero() {
+		days := now.Sub(r.PublishTime).Hours() / 24
+		if days < 0 {
+			days = 0
+		}
+		score += scoreRecency * recencyHalfLife / (recencyHalfLife + days)
+	}
+
+	// 搜索源优先级（1最高）
+	if priority > 0 {
+		score += scoreSource / float64(priority)
+	}
+
+	// 链接数量
+	links := len(r.Links)
+	if links > maxScoredLinkCount {
+		links = maxScoredLinkCount
+	}
+	score += scorePerLink * float64(links)
+
+	return score
+}
+
+// coverage 返回 parts 中出现在 text 里的比例
+func coverage(text string, parts []string) float64 {
+	if len(parts) == 0 || text == "" {
+		return 0
+	}
+	hit := 0
+	for _, part := range parts {
+		if strings.Contains(text, part) {
+			hit++
+		}
+	}
+	return float64(hit) / float64(len(parts))
+}
+
+// normalizeText 转小写并去除空白和标点，用于整体匹配
+func normalizeText(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// tokenize 按空白和标点切分关键词
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// bigrams 将词中连续的中文字符切分为双字组合，弥补中文没有空格分词的问题
+func bigrams(token string) []string {
+	var result []string
+	var run []rune
+	flush := func() {
+		for i := 0; i+1 < len(run); i++ {
+			result = append(result, string(run[i:i+2]))
+		}
+		run = run[:0]
+	}
+	for _, r := range token {
+		if unicode.Is(unicode.Han, r) {
+			run = append(run, r)
+		} else {
+			flush()
+		}
+	}
+	flush()
+	return result
+}
@@ -1,124 +1,425 @@
 package search
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"pansou-openwrt/internal/cache"
 	"pansou-openwrt/internal/config"
+	"pansou-openwrt/internal/httpclient"
+	"pansou-openwrt/internal/linkcheck"
 	"pansou-openwrt/internal/model"
 	"pansou-openwrt/internal/plugin"
 	"pansou-openwrt/internal/telegram"
 )
 
+// telegramPriority Telegram结果的排序优先级
+const telegramPriority = 2
+
 // Service 搜索服务
 type Service struct {
-	config        *config.Config
+	config        atomic.Pointer[config.Config]
 	pluginManager *plugin.Manager
-	tgClient      *telegram.Client
-	cache         *cache
+	clients       *httpclient.Factory
+	tgClient      atomic.Pointer[telegram.Client] // 未启用TG搜索时为nil
+	cache         *cache.Cache
+	flight        flightGroup
+	checker       *linkcheck.Checker
+
+	// ctx 是合并执行的搜索和后台刷新的父context，服务关闭时取消
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // NewService 创建搜索服务
-func NewService(cfg *config.Config, pm *plugin.Manager) *Service {
+func NewService(cfg *config.Config, pm *plugin.Manager, clients *httpclient.Factory) *Service {
 	// 创建Telegram客户端
 	var tgClient *telegram.Client
 	if cfg.Telegram.Enabled {
-		tgClient = telegram.NewClient(&cfg.Telegram)
+		tgClient = telegram.NewClient(&cfg.Telegram, cfg.Search.Concurrency, clients)
 	}
 
-	return &Service{
-		config:        cfg,
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// 网盘接口均在国内，链接检测始终直连
+	checker := linkcheck.New(clients.Client(httpclient.ModeDirect, linkCheckTimeout), linkcheck.DefaultEndpoints)
+	checker.SetTTL(time.Duration(cfg.Search.LinkCheckTTL) * time.Minute)
+
+	s := &Service{
 		pluginManager: pm,
-		tgClient:      tgClient,
-		cache:         newCache(time.Duration(cfg.Search.CacheTTL) * time.Minute),
+		clients:       clients,
+		cache:         newCache(&cfg.Search),
+		checker:       checker,
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	s.config.Store(cfg)
+	s.tgClient.Store(tgClient)
+	return s
+}
+
+// Apply 应用新配置：并发数、超时时间、缓存时间和链接检测设置从下一次搜索开始生效；
+// 启用或停用TG搜索、或TG配置需要重建后台任务时重新创建Telegram客户端；
+// 影响搜索结果的配置（插件、频道、网盘类型）变化时清空缓存
+// 缓存容量和缓存目录在重启后生效
+func (s *Service) Apply(cfg *config.Config) {
+	old := s.config.Swap(cfg)
+
+	tg := s.tgClient.Load()
+	switch {
+	case !cfg.Telegram.Enabled:
+		if tg != nil {
+			s.tgClient.Store(nil)
+			tg.Close()
+			log.Println("[TG] 已停用TG搜索")
+		}
+	case tg == nil || tg.NeedsRestart(&cfg.Telegram):
+		if tg != nil {
+			tg.Close()
+		}
+		s.tgClient.Store(telegram.NewClient(&cfg.Telegram, cfg.Search.Concurrency, s.clients))
+		log.Println("[TG] 已按新配置重新创建Telegram客户端")
+	default:
+		tg.Apply(&cfg.Telegram, cfg.Search.Concurrency)
 	}
+
+	s.checker.SetTTL(time.Duration(cfg.Search.LinkCheckTTL) * time.Minute)
+
+	if resultsChanged(old, cfg) {
+		s.cache.Clear()
+		log.Println("[缓存] 搜索相关配置已变化，已清空缓存")
+	}
+}
+
+// resultsChanged 判断新配置是否会改变相同请求的搜索结果
+func resultsChanged(old, cfg *config.Config) bool {
+	return !reflect.DeepEqual(old.Plugins, cfg.Plugins) ||
+		!reflect.DeepEqual(old.CloudTypes, cfg.CloudTypes) ||
+		old.Telegram.Enabled != cfg.Telegram.Enabled ||
+		!reflect.DeepEqual(old.Telegram.Channels, cfg.Telegram.Channels) ||
+		old.Telegram.MaxPages != cfg.Telegram.MaxPages ||
+		old.Telegram.MaxMessages != cfg.Telegram.MaxMessages ||
+		old.Telegram.MaxAgeDays != cfg.Telegram.MaxAgeDays
 }
 
+// cfg 返回当前配置
+func (s *Service) cfg() *config.Config {
+	return s.config.Load()
+}
+
+// TelegramHealth 返回Telegram连通性状态，未启用TG搜索时返回nil
+func (s *Service) TelegramHealth() *model.TelegramHealth {
+	tg := s.tgClient.Load()
+	if tg == nil {
+		return nil
+	}
+	h := tg.Health()
+	return &h
+}
+
+// CheckTelegram 立即检查Telegram连通性，未启用TG搜索时返回nil
+func (s *Service) CheckTelegram(ctx context.Context) *model.TelegramHealth {
+	tg := s.tgClient.Load()
+	if tg == nil {
+		return nil
+	}
+	h := tg.Check(ctx)
+	return &h
+}
+
+// CacheStats 返回各缓存层的条目数和占用字节数
+func (s *Service) CacheStats() []cache.TierStats {
+	return s.cache.Stats()
+}
+
+// Close 停止后台任务
+func (s *Service) Close() {
+	s.cancel()
+	if tg := s.tgClient.Load(); tg != nil {
+		tg.Close()
+	}
+	s.cache.Close()
+	s.checker.Close()
+}
+
+// SourceHandler 单个搜索源（插件或Telegram）完成时的回调
+// 回调在搜索协程中串行调用，不会并发执行
+type SourceHandler func(event model.SearchSourceEvent)
+
 // Search 执行搜索
-func (s *Service) Search(req *model.SearchRequest) (*model.SearchResponse, error) {
-	// 检查缓存
-	cacheKey := s.buildCacheKey(req)
-	if !req.ForceRefresh {
-		if cached, ok := s.cache.Get(cacheKey); ok {
-			log.Printf("缓存命中: %s", cacheKey)
-			result := cached.(*model.SearchResponse)
-			result.CacheHit = true
-			return result, nil
+// 缓存过期但仍在 CacheStale 期限内时立即返回旧结果，并在后台刷新
+// 相同的并发搜索只执行一次，ctx 结束时本次调用立即返回，不影响其他等待者；所有等待者都退出后搜索被取消
+// 请求了分页时从完整结果中截取当前页，链接检测只检测当前页中的链接，检测结果不写入搜索缓存
+// 请求中带有翻页游标时使用游标中保存的请求，结果集在签发游标后已更新时返回 ErrCursorExpired
+// 关键词按高级搜索语法解析（见 advancedQuery），只有普通词和短语发送给搜索源，其余条件在合并后的结果上过滤
+// 过滤条件不同但关键词相同的搜索共用同一份缓存
+func (s *Service) Search(ctx context.Context, req *model.SearchRequest) (*model.SearchResponse, error) {
+	req, version, err := resolveCursor(req)
+	if err != nil {
+		return nil, err
+	}
+	q, err := parseQuery(req.Keyword)
+	if err != nil {
+		return nil, err
+	}
+
+	upstream := *req
+	upstream.Keyword = q.keyword
+	resp, err := s.searchShared(ctx, &upstream)
+	if err != nil {
+		return nil, err
+	}
+	q.apply(resp)
+	if err := s.page(req, resp, version); err != nil {
+		return nil, err
+	}
+	s.checkLinks(ctx, req, resp)
+	return resp, nil
+}
+
+// page 对完整结果分页，version 不为0（来自翻页游标）时要求结果集与签发游标时相同
+func (s *Service) page(req *model.SearchRequest, resp *model.SearchResponse, version uint32) error {
+	current := resultVersion(resp)
+	if version != 0 && version != current {
+		return ErrCursorExpired
+	}
+	paginate(req, resp, current)
+	return nil
+}
+
+// searchShared 从缓存或合并执行的搜索中获取结果
+func (s *Service) searchShared(ctx context.Context, req *model.SearchRequest) (*model.SearchResponse, error) {
+	cacheKey := buildCacheKey(req)
+	if result, ok := s.lookupCache(cacheKey, req); ok {
+		return result, nil
+	}
+
+	// 复制请求，其他等待者仍在等待时搜索在调用方返回后继续执行
+	r := *req
+	call, started := s.flight.Do(s.ctx, cacheKey, func(ctx context.Context) ([]byte, error) {
+		_, data, err := s.search(ctx, cacheKey, &r, nil)
+		return data, err
+	})
+	if !started {
+		log.Printf("合并相同的搜索请求: %s", cacheKey)
+	}
+
+	data, err := s.flight.Wait(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+	return decodeResponse(data)
+}
+
+// SearchStream 执行搜索，每个搜索源完成后立即通过 onSource 回调通知
+// 缓存命中时不会触发 onSource；流式搜索需要逐个回调搜索源事件，不与其他请求合并
+// ctx 通常来自HTTP请求，客户端断开或超过 Search.Timeout 时所有插件和TG请求都会被取消
+// 链接检测和分页只作用于最终返回的结果，搜索源事件中的结果不分页、链接不做检测，但同样按搜索语句过滤
+func (s *Service) SearchStream(ctx context.Context, req *model.SearchRequest, onSource SourceHandler) (*model.SearchResponse, error) {
+	req, version, err := resolveCursor(req)
+	if err != nil {
+		return nil, err
+	}
+	q, err := parseQuery(req.Keyword)
+	if err != nil {
+		return nil, err
+	}
+
+	upstream := *req
+	upstream.Keyword = q.keyword
+	if onSource != nil && q.hasFilters() {
+		handler := onSource
+		onSource = func(event model.SearchSourceEvent) {
+			event.Results = q.filter(event.Results)
+			event.Count = len(event.Results)
+			handler(event)
+		}
+	}
+
+	cacheKey := buildCacheKey(&upstream)
+	resp, ok := s.lookupCache(cacheKey, &upstream)
+	if !ok {
+		resp, _, err = s.search(ctx, cacheKey, &upstream, onSource)
+		if err != nil {
+			return nil, err
 		}
 	}
+	q.apply(resp)
+
+	if err := s.page(req, resp, version); err != nil {
+		return nil, err
+	}
+	s.checkLinks(ctx, req, resp)
+	return resp, nil
+}
+
+// lookupCache 检查缓存，命中过期结果时触发后台刷新
+func (s *Service) lookupCache(cacheKey string, req *model.SearchRequest) (*model.SearchResponse, bool) {
+	if req.ForceRefresh {
+		return nil, false
+	}
+
+	result, fresh, ok := s.getCached(cacheKey)
+	if !ok {
+		return nil, false
+	}
+
+	result.CacheHit = true
+	if fresh {
+		log.Printf("缓存命中: %s", cacheKey)
+	} else {
+		log.Printf("缓存已过期，返回旧结果并在后台刷新: %s", cacheKey)
+		result.Stale = true
+		s.refresh(cacheKey, req)
+	}
+	return result, true
+}
+
+// refresh 在后台重新搜索并更新缓存，同一个键已有搜索在执行时不再重复发起
+// 刷新不属于任何请求，只在服务关闭时取消
+func (s *Service) refresh(cacheKey string, req *model.SearchRequest) {
+	r := *req
+	s.flight.Go(s.ctx, cacheKey, func(ctx context.Context) ([]byte, error) {
+		_, data, err := s.search(ctx, cacheKey, &r, nil)
+		if err != nil {
+			log.Printf("后台刷新缓存失败: %s: %v", cacheKey, err)
+		}
+		return data, err
+	})
+}
+
+// search 并发执行所有搜索源，汇总排序后写入缓存
+// 返回搜索响应和编码后的数据（用于合并执行的搜索分发给每个等待者）
+func (s *Service) search(ctx context.Context, cacheKey string, req *model.SearchRequest, onSource SourceHandler) (*model.SearchResponse, []byte, error) {
+	// 整个搜索过程使用同一份配置，不受中途热加载影响
+	cfg := s.cfg()
+	tg := s.tgClient.Load()
+
+	// 整体搜索超时
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Search.Timeout)*time.Second)
+	defer cancel()
 
 	// 执行搜索
 	allResults := make([]model.SearchResult, 0)
+	priorities := make(map[string]int)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	
+
+	// 请求中指定的网盘类型优先，否则使用配置中启用的类型
+	cloudTypes := req.CloudTypes
+	if len(cloudTypes) == 0 {
+		cloudTypes = cfg.CloudTypes.Enabled
+	}
+
+	// collect 汇总单个搜索源的结果并通知回调
+	collect := func(source string, priority int, start time.Time, results []model.SearchResult, err error) {
+		// 过滤网盘类型
+		if len(cloudTypes) > 0 {
+			results = s.filterByCloudType(results, cloudTypes)
+		}
+		if results == nil {
+			results = []model.SearchResult{}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		allResults = append(allResults, results...)
+		for _, r := range results {
+			priorities[r.Source] = priority
+		}
+		if onSource != nil && ctx.Err() != context.Canceled {
+			event := model.SearchSourceEvent{
+				Source:  source,
+				Count:   len(results),
+				Elapsed: time.Since(start).Seconds(),
+				Results: results,
+			}
+			if err != nil {
+				event.Error = err.Error()
+			}
+			onSource(event)
+		}
+	}
+
 	concurrency := req.Concurrency
 	if concurrency <= 0 {
-		concurrency = s.config.Search.Concurrency
+		concurrency = cfg.Search.Concurrency
 	}
-	
+
 	// 使用信号量控制并发
 	sem := make(chan struct{}, concurrency)
 
 	// 插件搜索
 	if req.SourceType == "all" || req.SourceType == "plugin" {
 		plugins := s.getPluginsForSearch(req.Plugins)
-		
+
 		for _, p := range plugins {
+			// 获取信号量，请求已取消时不再启动新的插件
+			if !acquire(ctx, sem) {
+				break
+			}
 			wg.Add(1)
-			sem <- struct{}{} // 获取信号量
-			
+
 			go func(plug plugin.Plugin) {
 				defer wg.Done()
 				defer func() { <-sem }() // 释放信号量
-				
-				results, err := plug.Search(req.Keyword, req.Ext)
-				if err != nil {
+
+				start := time.Now()
+				results, err := s.pluginManager.Search(ctx, plug, req.Keyword, req.Ext)
+				// 已熔断的插件没有发起请求，同样发送事件让客户端知道该插件被跳过
+				if err != nil && !errors.Is(err, plugin.ErrCircuitOpen) {
 					log.Printf("插件 %s 搜索失败: %v", plug.Name(), err)
-					return
 				}
-				
-				mu.Lock()
-				allResults = append(allResults, results...)
-				mu.Unlock()
+
+				collect("plugin:"+plug.Name(), s.pluginManager.EffectivePriority(plug), start, results, err)
 			}(p)
 		}
 	}
 
 	// Telegram搜索
 	if req.SourceType == "all" || req.SourceType == "tg" {
-		if s.config.Telegram.Enabled && s.tgClient != nil && s.tgClient.IsAvailable() {
-			wg.Add(1)
-			sem <- struct{}{}
-			
-			go func() {
-				defer wg.Done()
-				defer func() { <-sem }()
-				
-				results, err := s.searchTelegram(req)
-				if err != nil {
-					log.Printf("Telegram搜索失败: %v", err)
-					return
-				}
-				
-				mu.Lock()
-				allResults = append(allResults, results...)
-				mu.Unlock()
-			}()
-		} else if s.config.Telegram.Enabled {
+		if cfg.Telegram.Enabled && tg != nil && tg.Searchable() {
+			if acquire(ctx, sem) {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+					defer func() { <-sem }()
+
+					start := time.Now()
+					// 未指定频道时由客户端搜索配置的频道和Bot收录的频道
+					results, err := tg.Search(ctx, req.Keyword, req.Channels)
+					if err != nil {
+						log.Printf("Telegram搜索失败: %v", err)
+					}
+
+					collect("tg", telegramPriority, start, results, err)
+				}()
+			}
+		} else if cfg.Telegram.Enabled {
 			log.Println("[TG] Telegram未启用或网络不可达，跳过TG搜索")
 		}
 	}
 
 	wg.Wait()
 
-	// 过滤网盘类型
-	if len(req.CloudTypes) > 0 {
-		allResults = s.filterByCloudType(allResults, req.CloudTypes)
+	// 客户端已断开或服务正在关闭，不缓存不完整的结果
+	if ctx.Err() == context.Canceled {
+		return nil, nil, ctx.Err()
 	}
 
+	// 合并不同来源的重复链接
+	allResults = dedupResults(allResults)
+
+	// 按相关性得分排序（关键词匹配、发布时间、搜索源优先级、链接数量）
+	rankResults(allResults, req.Keyword, priorities)
+
 	// 构建响应
 	resp := &model.SearchResponse{
 		Total:    len(allResults),
@@ -139,12 +440,18 @@ func (s *Service) Search(req *model.SearchRequest) (*model.SearchResponse, error
 	}
 
 	// 缓存结果
-	s.cache.Set(cacheKey, resp)
+	data, err := encodeResponse(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.cache.Set(cacheKey, data,
+		time.Duration(cfg.Search.CacheTTL)*time.Minute,
+		time.Duration(cfg.Search.CacheStale)*time.Minute)
 
-	return resp, nil
+	return resp, data, nil
 }
 
-// getPluginsForSearch 获取用于搜索的插件
+// getPluginsForSearch 获取用于搜索的插件（按优先级排序，优先获得并发名额）
 func (s *Service) getPluginsForSearch(requestedPlugins []string) []plugin.Plugin {
 	if len(requestedPlugins) > 0 {
 		// 使用指定的插件
@@ -154,28 +461,25 @@ func (s *Service) getPluginsForSearch(requestedPlugins []string) []plugin.Plugin
 				plugins = append(plugins, p)
 			}
 		}
+		s.pluginManager.SortByPriority(plugins)
 		return plugins
 	}
-	
+
 	// 使用所有启用的插件
 	return s.pluginManager.GetEnabledPlugins()
 }
 
-// searchTelegram 搜索Telegram
-func (s *Service) searchTelegram(req *model.SearchRequest) ([]model.SearchResult, error) {
-	if s.tgClient == nil {
-		return []model.SearchResult{}, nil
+// acquire 获取并发信号量，ctx 结束时返回false
+func acquire(ctx context.Context, sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
 	}
-
-	channels := req.Channels
-	if len(channels) == 0 {
-		channels = s.config.Telegram.Channels
-	}
-
-	return s.tgClient.Search(req.Keyword, channels)
 }
 
-// filterByCloudType 按网盘类型过滤
+// filterByCloudType 按网盘类型过滤，未识别类型的链接在 cloudTypes 包含 others 时保留
 func (s *Service) filterByCloudType(results []model.SearchResult, cloudTypes []string) []model.SearchResult {
 	typeMap := make(map[string]bool)
 	for _, t := range cloudTypes {
@@ -186,14 +490,14 @@ func (s *Service) filterByCloudType(results []model.SearchResult, cloudTypes []s
 	for _, result := range results {
 		hasMatchingLink := false
 		filteredLinks := make([]model.Link, 0)
-		
+
 		for _, link := range result.Links {
-			if typeMap[link.Type] {
+			if typeMap[mergeType(link.Type)] {
 				filteredLinks = append(filteredLinks, link)
 				hasMatchingLink = true
 			}
 		}
-		
+
 		if hasMatchingLink {
 			result.Links = filteredLinks
 			filtered = append(filtered, result)
@@ -203,93 +507,35 @@ func (s *Service) filterByCloudType(results []model.SearchResult, cloudTypes []s
 	return filtered
 }
 
-// mergeByType 按网盘类型合并结果
+// mergeType 返回链接类型在过滤和合并结果时使用的类型，未识别的类型归入 others
+func mergeType(typ string) string {
+	if config.IsKnownCloudType(typ) {
+		return typ
+	}
+	return config.OtherCloudType
+}
+
+// mergeByType 按网盘类型合并结果，未识别类型的链接合并到 others 中
 func (s *Service) mergeByType(results []model.SearchResult) map[string][]model.SearchResult {
 	merged := make(map[string][]model.SearchResult)
 
 	for _, result := range results {
 		for _, link := range result.Links {
-			if _, ok := merged[link.Type]; !ok {
-				merged[link.Type] = make([]model.SearchResult, 0)
+			typ := mergeType(link.Type)
+			if _, ok := merged[typ]; !ok {
+				merged[typ] = make([]model.SearchResult, 0)
 			}
-			
+
 			// 创建只包含当前类型链接的结果
 			singleResult := result
 			singleResult.Links = []model.Link{link}
-			merged[link.Type] = append(merged[link.Type], singleResult)
+			merged[typ] = append(merged[typ], singleResult)
 		}
 	}
 
-	return merged
-}
-
-// buildCacheKey 构建缓存键
-func (s *Service) buildCacheKey(req *model.SearchRequest) string {
-	return fmt.Sprintf("search:%s:%s:%s", req.Keyword, req.SourceType, req.ResultType)
-}
-
-// 简单的内存缓存
-type cache struct {
-	data map[string]*cacheItem
-	mu   sync.RWMutex
-	ttl  time.Duration
-}
-
-type cacheItem struct {
-	value      interface{}
-	expireTime time.Time
-}
-
-func newCache(ttl time.Duration) *cache {
-	c := &cache{
-		data: make(map[string]*cacheItem),
-		ttl:  ttl,
-	}
-	
-	// 启动清理协程
-	go c.cleanup()
-	
-	return c
-}
-
-func (c *cache) Get(key string) (interface{}, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	item, ok := c.data[key]
-	if !ok {
-		return nil, false
-	}
-
-	if time.Now().After(item.expireTime) {
-		return nil, false
+	for _, bucket := range merged {
+		sortByScore(bucket)
 	}
 
-	return item.value, true
-}
-
-func (c *cache) Set(key string, value interface{}) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.data[key] = &cacheItem{
-		value:      value,
-		expireTime: time.Now().Add(c.ttl),
-	}
-}
-
-func (c *cache) cleanup() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		c.mu.Lock()
-		now := time.Now()
-		for key, item := range c.data {
-			if now.After(item.expireTime) {
-				delete(c.data, key)
-			}
-		}
-		c.mu.Unlock()
-	}
+	return merged
 }
@@ -0,0 +1,61 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"pansou-openwrt/internal/config"
+	"pansou-openwrt/internal/model"
+	"pansou-openwrt/internal/plugin"
+)
+
+// failingPlugin 每次搜索都失败的测试插件
+type failingPlugin struct{}
+
+func (failingPlugin) Name() string        { return "broken" }
+func (failingPlugin) DisplayName() string { return "broken" }
+func (failingPlugin) Description() string { return "" }
+func (failingPlugin) Priority() int       { return 1 }
+func (failingPlugin) Info() plugin.Info   { return plugin.Info{Name: "broken", Enabled: true} }
+
+func (failingPlugin) Search(ctx context.Context, keyword string, ext map[string]interface{}) ([]model.SearchResult, error) {
+	return nil, errors.New("连接失败")
+}
+
+func TestSearchStreamReportsCircuitOpenPlugin(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Search.Timeout = 10
+	cfg.Search.Concurrency = 2
+	cfg.Plugins.Enabled = true
+
+	pm := plugin.NewManager(cfg, nil)
+	t.Cleanup(pm.Close)
+	pm.Register(failingPlugin{})
+	s := NewService(cfg, pm, nil)
+
+	// 连续失败直到熔断
+	for i := 0; i < 5; i++ {
+		pm.Search(context.Background(), failingPlugin{}, "三体", nil)
+	}
+
+	var events []model.SearchSourceEvent
+	_, err := s.SearchStream(context.Background(), &model.SearchRequest{
+		Keyword:    "三体",
+		SourceType: "plugin",
+		ResultType: "all",
+	}, func(event model.SearchSourceEvent) {
+		events = append(events, event)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("收到 %d 个事件, want 1", len(events))
+	}
+	if e := events[0]; e.Source != "plugin:broken" || !strings.Contains(e.Error, plugin.ErrCircuitOpen.Error()) || e.Count != 0 {
+		t.Errorf("事件 = %+v", e)
+	}
+}
@@ -1,11 +1,17 @@
 package server
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"pansou-openwrt/internal/config"
+	"pansou-openwrt/internal/httpclient"
+	"pansou-openwrt/internal/linkcheck"
 	"pansou-openwrt/internal/model"
+	"pansou-openwrt/internal/search"
 )
 
 // handleHealth 健康检查
@@ -16,40 +22,44 @@ func (s *Server) handleHealth(c *gin.Context) {
 		pluginNames = append(pluginNames, p.Name())
 	}
 
+	cfg := s.cfg()
 	resp := model.HealthResponse{
 		Status:          "ok",
-		PluginsEnabled:  s.config.Plugins.Enabled,
+		PluginsEnabled:  cfg.Plugins.Enabled,
 		PluginCount:     len(plugins),
 		PluginNames:     pluginNames,
-		ChannelsCount:   len(s.config.Telegram.Channels),
-		Channels:        s.config.Telegram.Channels,
-		TelegramEnabled: s.config.Telegram.Enabled,
+		ChannelsCount:   len(cfg.Telegram.Channels),
+		Channels:        cfg.Telegram.Channels,
+		TelegramEnabled: cfg.Telegram.Enabled,
+		PluginHealth:    s.pluginManager.HealthAll(),
+		Telegram:        s.searchService.TelegramHealth(),
+		Proxy:           s.clients.Health(),
 	}
 
 	c.JSON(http.StatusOK, resp)
 }
 
+// handleTelegramCheck 立即检查Telegram连通性
+func (s *Server) handleTelegramCheck(c *gin.Context) {
+	health := s.searchService.CheckTelegram(c.Request.Context())
+	if health == nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    400,
+			Message: "Telegram搜索未启用",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, health)
+}
+
 // handleSearch 搜索处理
 func (s *Server) handleSearch(c *gin.Context) {
 	var req model.SearchRequest
 
 	// 根据请求方法解析参数
 	if c.Request.Method == "GET" {
-		req.Keyword = c.Query("kw")
-		req.SourceType = c.DefaultQuery("src", "all")
-		req.ResultType = c.DefaultQuery("res", "merge")
-		req.ForceRefresh = c.Query("refresh") == "true"
-		
-		// 解析数组参数
-		if channels := c.QueryArray("channels"); len(channels) > 0 {
-			req.Channels = channels
-		}
-		if plugins := c.QueryArray("plugins"); len(plugins) > 0 {
-			req.Plugins = plugins
-		}
-		if cloudTypes := c.QueryArray("cloud_types"); len(cloudTypes) > 0 {
-			req.CloudTypes = cloudTypes
-		}
+		parseSearchQuery(c, &req)
 	} else {
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, model.ErrorResponse{
@@ -61,10 +71,10 @@ func (s *Server) handleSearch(c *gin.Context) {
 	}
 
 	// 验证参数
-	if req.Keyword == "" {
+	if msg := validateSearchRequest(&req); msg != "" {
 		c.JSON(http.StatusBadRequest, model.ErrorResponse{
 			Code:    400,
-			Message: "搜索关键词不能为空",
+			Message: msg,
 		})
 		return
 	}
@@ -79,12 +89,13 @@ func (s *Server) handleSearch(c *gin.Context) {
 
 	// 执行搜索
 	startTime := time.Now()
-	result, err := s.searchService.Search(&req)
+	result, err := s.searchService.Search(c.Request.Context(), &req)
 	searchTime := time.Since(startTime).Seconds()
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
-			Code:    500,
+		status := searchErrorStatus(err)
+		c.JSON(status, model.ErrorResponse{
+			Code:    status,
 			Message: "搜索失败: " + err.Error(),
 		})
 		return
@@ -94,30 +105,134 @@ func (s *Server) handleSearch(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+// handleSearchStream 流式搜索处理（Server-Sent Events）
+// 每个搜索源完成后发送一个 source 事件，最后发送 done 事件（完整的搜索响应）
+func (s *Server) handleSearchStream(c *gin.Context) {
+	var req model.SearchRequest
+	parseSearchQuery(c, &req)
+
+	if msg := validateSearchRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    400,
+			Message: msg,
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	startTime := time.Now()
+	result, err := s.searchService.SearchStream(c.Request.Context(), &req, func(event model.SearchSourceEvent) {
+		c.SSEvent("source", event)
+		c.Writer.Flush()
+	})
+
+	if err != nil {
+		// 客户端已断开时无需再写入
+		if c.Request.Context().Err() == nil {
+			c.SSEvent("error", model.ErrorResponse{
+				Code:    searchErrorStatus(err),
+				Message: "搜索失败: " + err.Error(),
+			})
+			c.Writer.Flush()
+		}
+		return
+	}
+
+	result.SearchTime = time.Since(startTime).Seconds()
+	c.SSEvent("done", result)
+	c.Writer.Flush()
+}
+
+// validateSearchRequest 验证搜索参数，返回错误信息，参数有效时返回空字符串
+// 带有翻页游标时其他参数来自游标，不需要关键词
+func validateSearchRequest(req *model.SearchRequest) string {
+	if req.Keyword == "" && req.Cursor == "" {
+		return "搜索关键词不能为空"
+	}
+	if !linkcheck.ValidMode(req.ValidateLinks) {
+		return "无效的链接检测方式: " + req.ValidateLinks
+	}
+	if req.Page < 0 || req.PageSize < 0 || req.TypeLimit < 0 {
+		return "无效的分页参数"
+	}
+	return ""
+}
+
+// searchErrorStatus 返回搜索错误对应的HTTP状态码
+func searchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, search.ErrInvalidCursor), errors.Is(err, search.ErrInvalidQuery):
+		return http.StatusBadRequest
+	case errors.Is(err, search.ErrCursorExpired):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// parseSearchQuery 从URL查询参数解析搜索请求并设置默认值
+func parseSearchQuery(c *gin.Context, req *model.SearchRequest) {
+	req.Keyword = c.Query("kw")
+	req.SourceType = c.DefaultQuery("src", "all")
+	req.ResultType = c.DefaultQuery("res", "merge")
+	req.ForceRefresh = c.Query("refresh") == "true"
+	req.ValidateLinks = c.Query("validate_links")
+	req.Page, _ = strconv.Atoi(c.Query("page"))
+	req.PageSize, _ = strconv.Atoi(c.Query("page_size"))
+	req.TypeLimit, _ = strconv.Atoi(c.Query("type_limit"))
+	req.Cursor = c.Query("cursor")
+
+	// 解析数组参数
+	if channels := c.QueryArray("channels"); len(channels) > 0 {
+		req.Channels = channels
+	}
+	if plugins := c.QueryArray("plugins"); len(plugins) > 0 {
+		req.Plugins = plugins
+	}
+	if cloudTypes := c.QueryArray("cloud_types"); len(cloudTypes) > 0 {
+		req.CloudTypes = cloudTypes
+	}
+}
+
 // handleGetConfig 获取配置
 func (s *Server) handleGetConfig(c *gin.Context) {
+	cfg := s.cfg()
 	resp := model.ConfigResponse{
 		Server: map[string]interface{}{
-			"port":      s.config.Server.Port,
-			"enabled":   s.config.Server.Enabled,
-			"autostart": s.config.Server.Autostart,
+			"port":      cfg.Server.Port,
+			"enabled":   cfg.Server.Enabled,
+			"autostart": cfg.Server.Autostart,
 		},
 		Search: map[string]interface{}{
-			"concurrency": s.config.Search.Concurrency,
-			"timeout":     s.config.Search.Timeout,
-			"cache_ttl":   s.config.Search.CacheTTL,
+			"concurrency": cfg.Search.Concurrency,
+			"timeout":     cfg.Search.Timeout,
+			"cache_ttl":   cfg.Search.CacheTTL,
+			"cache_stale": cfg.Search.CacheStale,
+
+			"cache_max_entries":  cfg.Search.CacheMaxEntries,
+			"cache_max_memory":   cfg.Search.CacheMaxMemory,
+			"cache_dir":          cfg.Search.CacheDir,
+			"cache_dir_max_size": cfg.Search.CacheDirMaxSize,
+
+			"validate_links": cfg.Search.ValidateLinks,
+			"link_check_ttl": cfg.Search.LinkCheckTTL,
 		},
 		Telegram: map[string]interface{}{
-			"enabled":       s.config.Telegram.Enabled,
-			"channels":      s.config.Telegram.Channels,
-			"check_timeout": s.config.Telegram.CheckTimeout,
+			"enabled":       cfg.Telegram.Enabled,
+			"channels":      cfg.Telegram.Channels,
+			"check_timeout": cfg.Telegram.CheckTimeout,
 		},
 		Plugins: map[string]interface{}{
-			"enabled": s.config.Plugins.Enabled,
-			"list":    s.config.Plugins.List,
+			"enabled": cfg.Plugins.Enabled,
+			"list":    cfg.Plugins.List,
 		},
 		CloudTypes: map[string]interface{}{
-			"enabled": s.config.CloudTypes.Enabled,
+			"enabled": cfg.CloudTypes.Enabled,
 		},
 	}
 
@@ -125,6 +240,7 @@ func (s *Server) handleGetConfig(c *gin.Context) {
 }
 
 // handleUpdateConfig 更新配置
+// 请求体为部分配置（结构与配置文件相同），验证通过后写入配置文件并立即应用到运行中的服务
 func (s *Server) handleUpdateConfig(c *gin.Context) {
 	var updates map[string]interface{}
 	if err := c.ShouldBindJSON(&updates); err != nil {
@@ -135,12 +251,41 @@ func (s *Server) handleUpdateConfig(c *gin.Context) {
 		return
 	}
 
-	// TODO: 实现配置更新逻辑
-	// 这里需要更新配置并保存到文件
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+
+	cfg, err := s.cfg().Update(updates)
+	if err == nil {
+		err = httpclient.ValidateProxy(cfg.Proxy.URL)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ErrorResponse{
+			Code:    400,
+			Message: "配置无效: " + err.Error(),
+		})
+		return
+	}
+
+	if s.source != nil {
+		if err := s.source.Save(cfg); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, config.ErrOverlayField) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, model.ErrorResponse{
+				Code:    status,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	restart := s.apply(cfg)
 
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "配置更新成功",
+		"success":          true,
+		"message":          "配置更新成功",
+		"restart_required": restart,
 	})
 }
 
@@ -154,8 +299,11 @@ func (s *Server) handleGetPlugins(c *gin.Context) {
 			"name":         p.Name(),
 			"display_name": p.DisplayName(),
 			"description":  p.Description(),
-			"priority":     p.Priority(),
-			"enabled":      true, // TODO: 从配置读取
+			"priority":     s.pluginManager.EffectivePriority(p),
+			"enabled":      s.pluginManager.IsEnabled(p),
+			"cloud_types":  p.Info().CloudTypes,
+			"proxy":        s.pluginManager.ProxyMode(p.Name()),
+			"health":       s.pluginManager.Health(p.Name()),
 		})
 	}
 
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"pansou-openwrt/internal/search"
+)
+
+func TestSearchErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{search.ErrInvalidCursor, http.StatusBadRequest},
+		{search.ErrInvalidQuery, http.StatusBadRequest},
+		{fmt.Errorf("%w: 缺少搜索关键词", search.ErrInvalidQuery), http.StatusBadRequest},
+		{search.ErrCursorExpired, http.StatusConflict},
+		{fmt.Errorf("翻页失败: %w", search.ErrCursorExpired), http.StatusConflict},
+		{errors.New("搜索超时"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := searchErrorStatus(tt.err); got != tt.want {
+			t.Errorf("searchErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -4,44 +4,117 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"pansou-openwrt/internal/config"
+	"pansou-openwrt/internal/httpclient"
+	"pansou-openwrt/internal/model"
 	"pansou-openwrt/internal/plugin"
 	"pansou-openwrt/internal/search"
 )
 
 // Server HTTP服务器
 type Server struct {
-	config        *config.Config
+	config        atomic.Pointer[config.Config]
+	source        *config.Source // 配置来源，通过API修改的配置写回此处
+	applyMu       sync.Mutex     // 串行化配置更新
 	httpServer    *http.Server
 	searchService *search.Service
 	pluginManager *plugin.Manager
+	clients       *httpclient.Factory
 }
 
-// New 创建新服务器
-func New(cfg *config.Config) (*Server, error) {
+// New 创建新服务器，source 为nil时通过API修改的配置不会保存
+func New(cfg *config.Config, source *config.Source) (*Server, error) {
+	// 创建出站HTTP客户端工厂，代理无效时仍可直连
+	clients, err := httpclient.New(cfg.Proxy.URL)
+	if err != nil {
+		log.Printf("[代理] %v，将直连", err)
+	}
+
 	// 创建插件管理器
-	pluginMgr := plugin.NewManager(cfg)
+	pluginMgr := plugin.NewManager(cfg, clients)
 
 	// 创建搜索服务
-	searchSrv := search.NewService(cfg, pluginMgr)
+	searchSrv := search.NewService(cfg, pluginMgr, clients)
 
 	// 创建服务器
 	srv := &Server{
-		config:        cfg,
+		source:        source,
 		searchService: searchSrv,
 		pluginManager: pluginMgr,
+		clients:       clients,
 	}
+	srv.config.Store(cfg)
 
 	return srv, nil
 }
 
+// cfg 返回当前配置
+func (s *Server) cfg() *config.Config {
+	return s.config.Load()
+}
+
+// ApplyConfig 将新配置应用到运行中的服务，返回需要重启才能生效的配置项
+// 配置与当前完全相同时不做任何操作；cfg 必须已通过 Validate，应用后不得再修改
+func (s *Server) ApplyConfig(cfg *config.Config) []string {
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+
+	if reflect.DeepEqual(s.cfg(), cfg) {
+		log.Println("配置未变化")
+		return nil
+	}
+	return s.apply(cfg)
+}
+
+// apply 应用新配置，调用方需持有 applyMu
+func (s *Server) apply(cfg *config.Config) []string {
+	old := s.config.Swap(cfg)
+
+	if err := s.clients.SetProxy(cfg.Proxy.URL); err != nil {
+		log.Printf("[代理] %v，将直连", err)
+	}
+	s.pluginManager.Apply(cfg)
+	s.searchService.Apply(cfg)
+
+	restart := restartRequired(old, cfg)
+	if len(restart) > 0 {
+		log.Printf("配置已更新，以下配置需重启后生效: %v", restart)
+	} else {
+		log.Println("配置已更新")
+	}
+	return restart
+}
+
+// restartRequired 返回发生变化但无法在运行时应用的配置项
+func restartRequired(old, cfg *config.Config) []string {
+	restart := make([]string, 0)
+	if old.Server != cfg.Server {
+		restart = append(restart, "server")
+	}
+	if old.Search.CacheMaxEntries != cfg.Search.CacheMaxEntries ||
+		old.Search.CacheMaxMemory != cfg.Search.CacheMaxMemory ||
+		old.Search.CacheDir != cfg.Search.CacheDir ||
+		old.Search.CacheDirMaxSize != cfg.Search.CacheDirMaxSize {
+		restart = append(restart, "search.cache")
+	}
+	if old.Logging != cfg.Logging {
+		restart = append(restart, "logging")
+	}
+	return restart
+}
+
 // Start 启动服务器
 func (s *Server) Start() error {
-	if !s.config.Server.Enabled {
+	cfg := s.cfg()
+	if !cfg.Server.Enabled {
 		return fmt.Errorf("服务器未启用")
 	}
 
@@ -53,13 +126,13 @@ func (s *Server) Start() error {
 
 	// 创建HTTP服务器
 	s.httpServer = &http.Server{
-		Addr:    fmt.Sprintf(":%d", s.config.Server.Port),
+		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler: router,
 	}
 
 	// 启动服务器
 	go func() {
-		log.Printf("HTTP服务器启动在端口 %d", s.config.Server.Port)
+		log.Printf("HTTP服务器启动在端口 %d", cfg.Server.Port)
 		if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("HTTP服务器错误: %v", err)
 		}
@@ -75,48 +148,77 @@ func (s *Server) Shutdown() {
 		defer cancel()
 		s.httpServer.Shutdown(ctx)
 	}
+	s.searchService.Close()
+	s.pluginManager.Close()
+	s.clients.Close()
 }
 
 // setupRouter 设置路由
 func (s *Server) setupRouter() *gin.Engine {
 	r := gin.New()
 	r.Use(gin.Recovery())
-	r.Use(corsMiddleware())
 	r.Use(loggerMiddleware())
 
 	// API路由组
 	api := r.Group("/api")
 	{
+		// 只读接口允许浏览器跨域调用
+		public := api.Group("", corsMiddleware())
+
 		// 健康检查
-		api.GET("/health", s.handleHealth)
+		public.GET("/health", s.handleHealth)
 
 		// 搜索接口
-		api.POST("/search", s.handleSearch)
-		api.GET("/search", s.handleSearch)
+		public.POST("/search", s.handleSearch)
+		public.GET("/search", s.handleSearch)
+		public.GET("/search/stream", s.handleSearchStream)
 
-		// 配置管理
-		api.GET("/config", s.handleGetConfig)
-		api.POST("/config", s.handleUpdateConfig)
+		// 配置管理，修改配置只允许本机（LuCI 通过 127.0.0.1 转发）调用
+		public.GET("/config", s.handleGetConfig)
+		api.POST("/config", localOnly(), s.handleUpdateConfig)
 
 		// 插件信息
-		api.GET("/plugins", s.handleGetPlugins)
+		public.GET("/plugins", s.handleGetPlugins)
+
+		// Telegram连通性检查，会立即发起网络请求，同样只允许本机调用
+		api.POST("/telegram/check", localOnly(), s.handleTelegramCheck)
+
+		// 跨域预检请求
+		for _, path := range []string{"/health", "/search", "/search/stream", "/plugins"} {
+			public.OPTIONS(path, handlePreflight)
+		}
 	}
 
 	return r
 }
 
-// CORS中间件
+// CORS中间件，只用于不修改状态的接口
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Next()
+	}
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+// handlePreflight 响应跨域预检请求
+func handlePreflight(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
+// localOnly 只允许回环地址调用
+// 按连接的对端地址判断，不使用可伪造的 X-Forwarded-For 等请求头
+func localOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := net.ParseIP(c.RemoteIP())
+		if ip == nil || !ip.IsLoopback() {
+			c.AbortWithStatusJSON(http.StatusForbidden, model.ErrorResponse{
+				Code:    403,
+				Message: "只允许本机调用此接口",
+			})
 			return
 		}
-
 		c.Next()
 	}
 }
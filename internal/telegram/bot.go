@@ -0,0 +1,272 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"pansou-openwrt/internal/model"
+)
+
+const (
+	botAPIURL         = "https://api.telegram.org/bot%s/%s"
+	botPollTimeout    = 25               // getUpdates 长轮询时长（秒），需小于HTTP客户端超时
+	botRetryDelay     = 5 * time.Second  // 拉取失败后的首次重试间隔，之后逐次加倍
+	botMaxRetryDelay  = 5 * time.Minute  // 最长重试间隔
+	botRequestTimeout = 40 * time.Second // 单次Bot API请求超时
+)
+
+// bot 通过Bot API收录机器人作为管理员的频道消息
+// 使用 getUpdates 长轮询接收 channel_post，收录到本地索引供搜索
+type bot struct {
+	token      string
+	apiURL     string // Bot API地址格式，测试时可指向本地模拟服务
+	httpClient *http.Client
+	index      *index
+	done       chan struct{} // run 退出后关闭
+
+	mu        sync.Mutex
+	offset    int64
+	lastError string
+	lastPoll  time.Time
+}
+
+// botResponse Bot API通用响应
+type botResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// botUpdate getUpdates 返回的单条更新
+type botUpdate struct {
+	UpdateID          int64       `json:"update_id"`
+	ChannelPost       *botMessage `json:"channel_post"`
+	EditedChannelPost *botMessage `json:"edited_channel_post"`
+}
+
+// botMessage 频道消息
+type botMessage struct {
+	MessageID       int64       `json:"message_id"`
+	Date            int64       `json:"date"`
+	Chat            botChat     `json:"chat"`
+	Text            string      `json:"text"`
+	Caption         string      `json:"caption"`
+	Entities        []botEntity `json:"entities"`
+	CaptionEntities []botEntity `json:"caption_entities"`
+}
+
+// botChat 消息所在的频道
+type botChat struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Title    string `json:"title"`
+}
+
+// botEntity 消息中的格式化片段（链接等），偏移量以UTF-16编码单元计
+type botEntity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	URL    string `json:"url"`
+}
+
+func newBot(token string, httpClient *http.Client, idx *index) *bot {
+	return &bot{
+		token:      token,
+		apiURL:     botAPIURL,
+		httpClient: httpClient,
+		index:      idx,
+		done:       make(chan struct{}),
+	}
+}
+
+// run 持续拉取频道消息，直到 stop 关闭
+func (b *bot) run(stop <-chan struct{}) {
+	defer close(b.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	delay := botRetryDelay
+	for ctx.Err() == nil {
+		n, err := b.poll(ctx)
+		b.mu.Lock()
+		b.lastPoll = time.Now()
+		if err != nil {
+			b.lastError = err.Error()
+		} else {
+			b.lastError = ""
+		}
+		b.mu.Unlock()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[TG] Bot API拉取消息失败: %v，%v 后重试", err, delay)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+			if delay > botMaxRetryDelay {
+				delay = botMaxRetryDelay
+			}
+			continue
+		}
+
+		delay = botRetryDelay
+		if n > 0 {
+			log.Printf("[TG] Bot API收录 %d 条频道消息，索引共 %d 条", n, b.index.Len())
+		}
+	}
+}
+
+// wait 等待 run 退出
+// 同一个令牌同时只能有一个 getUpdates 请求，否则Telegram返回409，重建客户端前需等待旧的轮询结束
+func (b *bot) wait() {
+	<-b.done
+}
+
+// poll 拉取一次更新并写入索引，返回收录的消息数
+func (b *bot) poll(ctx context.Context) (int, error) {
+	b.mu.Lock()
+	offset := b.offset
+	b.mu.Unlock()
+
+	params := url.Values{}
+	params.Set("timeout", strconv.Itoa(botPollTimeout))
+	params.Set("allowed_updates", `["channel_post","edited_channel_post"]`)
+	if offset > 0 {
+		params.Set("offset", strconv.FormatInt(offset, 10))
+	}
+
+	var updates []botUpdate
+	if err := b.call(ctx, "getUpdates", params, &updates); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, u := range updates {
+		if u.UpdateID >= offset {
+			offset = u.UpdateID + 1
+		}
+		msg := u.ChannelPost
+		if msg == nil {
+			msg = u.EditedChannelPost
+		}
+		if msg == nil {
+			continue
+		}
+		if result, ok := msg.toResult(); ok {
+			b.index.AddBot(result)
+			count++
+		}
+	}
+
+	b.mu.Lock()
+	b.offset = offset
+	b.mu.Unlock()
+
+	return count, nil
+}
+
+// call 调用Bot API方法，错误信息中不会包含token
+func (b *bot) call(ctx context.Context, method string, params url.Values, result interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, botRequestTimeout)
+	defer cancel()
+
+	apiURL := fmt.Sprintf(b.apiURL, b.token, method)
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return b.redact(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// 长轮询可能超过共享客户端的超时时间，使用单独的超时设置
+	client := *b.httpClient
+	client.Timeout = botRequestTimeout
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return b.redact(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", b.redact(err))
+	}
+
+	var apiResp botResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return fmt.Errorf("JSON解析失败: %w", err)
+	}
+	if !apiResp.OK {
+		return fmt.Errorf("Bot API返回错误: %s", apiResp.Description)
+	}
+
+	if err := json.Unmarshal(apiResp.Result, result); err != nil {
+		return fmt.Errorf("JSON解析失败: %w", err)
+	}
+	return nil
+}
+
+// redact 去除错误信息中的Bot Token
+func (b *bot) redact(err error) error {
+	return errors.New(strings.ReplaceAll(err.Error(), b.token, "<token>"))
+}
+
+// status 返回最近一次拉取的时间和错误
+func (b *bot) status() (time.Time, string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastPoll, b.lastError
+}
+
+// toResult 将频道消息转换为搜索结果，没有网盘链接时返回false
+func (m *botMessage) toResult() (model.SearchResult, bool) {
+	text, entities := m.Text, m.Entities
+	if text == "" {
+		text, entities = m.Caption, m.CaptionEntities
+	}
+
+	// 频道没有公开用户名时使用频道ID
+	channel := m.Chat.Username
+	if channel == "" {
+		channel = strconv.FormatInt(m.Chat.ID, 10)
+	}
+
+	// 超链接文字与地址不同时（text_link），地址只存在于entity中
+	var candidates []string
+	runes := utf16.Encode([]rune(text))
+	for _, e := range entities {
+		switch e.Type {
+		case "text_link":
+			candidates = append(candidates, e.URL)
+		case "url":
+			if e.Offset >= 0 && e.Offset+e.Length <= len(runes) {
+				candidates = append(candidates, string(utf16.Decode(runes[e.Offset:e.Offset+e.Length])))
+			}
+		}
+	}
+
+	return buildResult(channel, strconv.FormatInt(m.MessageID, 10), strings.TrimSpace(text),
+		candidates, time.Unix(m.Date, 0))
+}
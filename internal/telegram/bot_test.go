@@ -0,0 +1,259 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pansou-openwrt/internal/config"
+)
+
+func TestBotMessageToResult(t *testing.T) {
+	date := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		msg     botMessage
+		ok      bool
+		channel string
+		title   string
+		links   []string
+	}{
+		{
+			name: "正文中的链接",
+			msg: botMessage{
+				MessageID: 10,
+				Date:      date.Unix(),
+				Chat:      botChat{ID: -1001, Username: "PublicRes"},
+				Text:      "三体 全集\n链接：https://pan.quark.cn/s/abc123",
+				Entities:  []botEntity{{Type: "url", Offset: 9, Length: 29}},
+			},
+			ok:      true,
+			channel: "PublicRes",
+			title:   "三体 全集",
+			links:   []string{"https://pan.quark.cn/s/abc123"},
+		},
+		{
+			name: "文字超链接",
+			msg: botMessage{
+				MessageID: 11,
+				Date:      date.Unix(),
+				Chat:      botChat{ID: -1001, Username: "PublicRes"},
+				Text:      "流浪地球2 点击下载",
+				Entities:  []botEntity{{Type: "text_link", Offset: 6, Length: 4, URL: "https://www.alipan.com/s/xyz789"}},
+			},
+			ok:      true,
+			channel: "PublicRes",
+			title:   "流浪地球2 点击下载",
+			links:   []string{"https://www.alipan.com/s/xyz789"},
+		},
+		{
+			name: "图片说明中的链接，私有频道使用频道ID",
+			msg: botMessage{
+				MessageID:       12,
+				Date:            date.Unix(),
+				Chat:            botChat{ID: -1002003004005, Title: "私有资源"},
+				Caption:         "漫长的季节\nhttps://pan.baidu.com/s/1AbCdEf?pwd=x1y2",
+				CaptionEntities: []botEntity{{Type: "url", Offset: 6, Length: 40}},
+			},
+			ok:      true,
+			channel: "-1002003004005",
+			title:   "漫长的季节",
+			links:   []string{"https://pan.baidu.com/s/1AbCdEf?pwd=x1y2"},
+		},
+		{
+			name: "没有网盘链接",
+			msg: botMessage{
+				MessageID: 13,
+				Chat:      botChat{Username: "PublicRes"},
+				Text:      "今天没有更新",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := tt.msg.toResult()
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if r.Channel != tt.channel || r.Source != "tg:"+tt.channel {
+				t.Errorf("channel = %q, source = %q", r.Channel, r.Source)
+			}
+			if want := tt.channel + ":" + strconv.FormatInt(tt.msg.MessageID, 10); r.UniqueID != want {
+				t.Errorf("UniqueID = %q, want %q", r.UniqueID, want)
+			}
+			if r.Title != tt.title {
+				t.Errorf("Title = %q, want %q", r.Title, tt.title)
+			}
+			if !r.PublishTime.Equal(date) {
+				t.Errorf("PublishTime = %v, want %v", r.PublishTime, date)
+			}
+			got := make([]string, 0, len(r.Links))
+			for _, link := range r.Links {
+				got = append(got, link.URL)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.links, " ") {
+				t.Errorf("Links = %v, want %v", got, tt.links)
+			}
+		})
+	}
+}
+
+// botStub 模拟Bot API，第一次 getUpdates 返回 updates，之后阻塞到请求取消
+func botStub(t *testing.T, updates []botUpdate) *httptest.Server {
+	t.Helper()
+
+	var sent atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/getUpdates") {
+			http.NotFound(w, r)
+			return
+		}
+		// 读完请求体后服务端才能察觉客户端断开
+		r.ParseForm()
+		if sent.Swap(true) {
+			<-r.Context().Done()
+			return
+		}
+		result, _ := json.Marshal(updates)
+		json.NewEncoder(w).Encode(botResponse{OK: true, Result: result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestClient 创建不访问网络的客户端，只使用本地索引
+func newTestClient(channels []string) *Client {
+	c := &Client{
+		index:   newIndex(""),
+		recheck: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+	c.config.Store(&config.TelegramConfig{Channels: channels, MaxMessages: 50})
+	c.concurrency.Store(1)
+	return c
+}
+
+func newTestBot(srv *httptest.Server, idx *index) *bot {
+	b := newBot("123:token", srv.Client(), idx)
+	b.apiURL = srv.URL + "/bot%s/%s"
+	return b
+}
+
+func TestBotIngestedChannelsAreSearchable(t *testing.T) {
+	now := time.Now().Unix()
+	srv := botStub(t, []botUpdate{
+		{UpdateID: 1, ChannelPost: &botMessage{
+			MessageID: 1, Date: now, Chat: botChat{Username: "configured"},
+			Text: "三体 第一季 https://pan.quark.cn/s/aaa111",
+		}},
+		{UpdateID: 2, ChannelPost: &botMessage{
+			MessageID: 2, Date: now, Chat: botChat{Username: "NotConfigured"},
+			Text: "三体 广播剧 https://pan.quark.cn/s/bbb222",
+		}},
+		{UpdateID: 3, EditedChannelPost: &botMessage{
+			MessageID: 3, Date: now, Chat: botChat{ID: -100777},
+			Text: "三体 原著 https://pan.quark.cn/s/ccc333",
+		}},
+		{UpdateID: 4, ChannelPost: &botMessage{
+			MessageID: 4, Date: now, Chat: botChat{Username: "configured"},
+			Text: "流浪地球 https://pan.quark.cn/s/ddd444",
+		}},
+	})
+
+	c := newTestClient([]string{"configured"})
+	b := newTestBot(srv, c.index)
+
+	n, err := b.poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Fatalf("收录了 %d 条消息, want 4", n)
+	}
+	if b.offset != 5 {
+		t.Errorf("offset = %d, want 5", b.offset)
+	}
+
+	channels := func(keyword string, requested []string) []string {
+		results, err := c.Search(context.Background(), keyword, requested)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0, len(results))
+		for _, r := range results {
+			got = append(got, r.Channel)
+		}
+		return got
+	}
+
+	// 未指定频道时包括未配置的频道和私有频道
+	if got := channels("三体", nil); len(got) != 3 {
+		t.Errorf("未指定频道时的结果来自 %v, want 3个频道", got)
+	}
+	// 指定频道时只搜索这些频道
+	if got := channels("三体", []string{"-100777"}); len(got) != 1 || got[0] != "-100777" {
+		t.Errorf("指定私有频道时的结果来自 %v", got)
+	}
+	if got := channels("三体", []string{"notconfigured"}); len(got) != 1 || got[0] != "NotConfigured" {
+		t.Errorf("指定频道（忽略大小写）时的结果来自 %v", got)
+	}
+}
+
+func TestIndexPersistsBotChannels(t *testing.T) {
+	dir := t.TempDir()
+	idx := newIndex(dir)
+	r, _ := (&botMessage{
+		MessageID: 1, Date: time.Now().Unix(), Chat: botChat{ID: -100888},
+		Text: "资源 https://pan.quark.cn/s/eee555",
+	}).toResult()
+	idx.AddBot(r)
+	if err := idx.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newIndex(dir)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.BotChannels(); len(got) != 1 || got[0] != "-100888" {
+		t.Errorf("BotChannels() = %v", got)
+	}
+}
+
+func TestCloseWaitsForBotPoller(t *testing.T) {
+	srv := botStub(t, nil)
+	c := newTestClient(nil)
+	c.bot = newTestBot(srv, c.index)
+	go c.bot.run(c.stop)
+
+	// 等待第二次（阻塞的）长轮询开始
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close 超时")
+	}
+
+	select {
+	case <-c.bot.done:
+	default:
+		t.Fatal("Close 返回时Bot轮询仍在运行")
+	}
+}
@@ -2,200 +2,354 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"pansou-openwrt/internal/config"
+	"pansou-openwrt/internal/httpclient"
 	"pansou-openwrt/internal/model"
 )
 
 // Client Telegram客户端
 type Client struct {
-	config     *config.TelegramConfig
-	httpClient *http.Client
-	available  bool
+	config      atomic.Pointer[config.TelegramConfig]
+	httpClient  *http.Client
+	concurrency atomic.Int64 // 同时搜索的频道数
+	proxied     bool         // 是否配置了代理
+	index       *index
+	bot         *bot // 未配置 bot_token 时为nil
+
+	// 连通性状态，由后台检查协程维护
+	available   atomic.Bool
+	statusMu    sync.Mutex
+	lastCheck   time.Time
+	lastLatency time.Duration
+	lastError   string
+	retryDelay  time.Duration
+	recheck     chan struct{}
+	stop        chan struct{}
+	closeOnce   sync.Once
 }
 
-// NewClient 创建Telegram客户端
-func NewClient(cfg *config.TelegramConfig) *Client {
+// NewClient 创建Telegram客户端，concurrency 为同时搜索的频道数
+// 配置了 telegram.proxy 时所有请求走代理，否则先直连、失败后走全局代理
+func NewClient(cfg *config.TelegramConfig, concurrency int, clients *httpclient.Factory) *Client {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
 	client := &Client{
-		config:    cfg,
-		available: false,
+		index:   newIndex(cfg.IndexDir),
+		recheck: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
 	}
+	client.config.Store(cfg)
+	client.concurrency.Store(int64(concurrency))
 
 	// 创建HTTP客户端
-	transport := &http.Transport{
-		MaxIdleConns:        10,
-		MaxIdleConnsPerHost: 5,
-		IdleConnTimeout:     30 * time.Second,
+	mode := httpclient.ModeAuto
+	if cfg.Proxy != "" {
+		mode = httpclient.ModeProxy
 	}
+	client.httpClient = clients.Client(mode, 30*time.Second)
+	client.proxied = clients.HasProxy()
 
-	// 如果配置了代理
-	if cfg.Proxy != "" {
-		proxyURL, err := url.Parse(cfg.Proxy)
-		if err == nil {
-			transport.Proxy = http.ProxyURL(proxyURL)
-			log.Printf("[TG] 使用代理: %s", cfg.Proxy)
-		} else {
-			log.Printf("[TG] 代理配置无效: %v", err)
-		}
+	// 后台协程立即检查一次网络连接，之后定期复查；不阻塞创建（热加载时持有配置锁）
+	// 首次检查完成前假定可用，搜索出现网络错误时会触发复查
+	client.available.Store(true)
+	go client.monitorLoop()
+
+	// 加载本地索引，定期写盘
+	if err := client.index.Load(); err != nil {
+		log.Printf("[TG] %v", err)
+	} else if n := client.index.Len(); n > 0 {
+		log.Printf("[TG] 已加载本地索引，共 %d 条消息", n)
 	}
+	go client.persistLoop()
 
-	client.httpClient = &http.Client{
-		Transport: transport,
-		Timeout:   30 * time.Second,
+	// 后台增量爬取频道消息
+	if cfg.CrawlInterval > 0 {
+		go client.crawlLoop(time.Duration(cfg.CrawlInterval) * time.Minute)
 	}
 
-	// 检查网络连接
-	client.checkAvailability()
+	// 配置了Bot Token时通过Bot API收录频道消息
+	if cfg.BotToken != "" {
+		client.bot = newBot(cfg.BotToken, client.httpClient, client.index)
+		go client.bot.run(client.stop)
+		log.Println("[TG] 已启用Bot API消息收录")
+	}
 
 	return client
 }
 
-// checkAvailability 检查Telegram是否可访问
-func (c *Client) checkAvailability() {
-	ctx, cancel := context.WithTimeout(context.Background(), 
-		time.Duration(c.config.CheckTimeout)*time.Second)
-	defer cancel()
+// Apply 应用新配置，频道列表、翻页数、结果数等在下一次搜索或爬取时生效
+// 需要重建后台任务的配置变化（见 NeedsRestart）不在此处理
+func (c *Client) Apply(cfg *config.TelegramConfig, concurrency int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	c.config.Store(cfg)
+	c.concurrency.Store(int64(concurrency))
+}
+
+// NeedsRestart 判断新配置是否需要重新创建客户端（代理、Bot令牌、爬取间隔或索引目录变化）
+func (c *Client) NeedsRestart(cfg *config.TelegramConfig) bool {
+	old := c.cfg()
+	return old.Proxy != cfg.Proxy ||
+		old.BotToken != cfg.BotToken ||
+		old.CrawlInterval != cfg.CrawlInterval ||
+		old.IndexDir != cfg.IndexDir
+}
+
+// cfg 返回当前配置
+func (c *Client) cfg() *config.TelegramConfig {
+	return c.config.Load()
+}
+
+// Searchable 返回当前是否可以搜索（网络可达或本地索引中有消息）
+func (c *Client) Searchable() bool {
+	return c.IsAvailable() || c.index.Len() > 0
+}
+
+// IsAvailable 返回Telegram是否可用
+func (c *Client) IsAvailable() bool {
+	return c.available.Load()
+}
 
-	// 尝试连接Telegram API
-	testURLs := []string{
-		"https://api.telegram.org",
-		"https://t.me",
+// Search 并发搜索多个Telegram频道，并发数受 Search.Concurrency 限制
+// 优先从本地索引（后台爬虫和Bot API收录）返回结果，只对索引已过期的频道实时抓取，
+// 因此 t.me 不可访问时仍能返回已收录的消息
+// 未指定频道时搜索配置的频道，以及Bot API收录过消息的所有频道（包括未配置的频道和私有频道）
+func (c *Client) Search(ctx context.Context, keyword string, channels []string) ([]model.SearchResult, error) {
+	indexed := channels
+	if len(channels) == 0 {
+		channels = c.cfg().Channels
+		indexed = appendMissing(channels, c.index.BotChannels())
 	}
 
-	for _, testURL := range testURLs {
-		req, err := http.NewRequestWithContext(ctx, "HEAD", testURL, nil)
-		if err != nil {
-			continue
-		}
+	results := c.searchIndex(keyword, indexed)
 
-		resp, err := c.httpClient.Do(req)
-		if err == nil {
-			resp.Body.Close()
-			if resp.StatusCode < 500 {
-				c.available = true
-				log.Println("[TG] Telegram网络连接正常")
-				return
-			}
+	// 爬取间隔的两倍内更新过的频道视为新鲜，无需实时抓取
+	stale := make([]string, 0, len(channels))
+	staleAfter := 2 * time.Duration(c.cfg().CrawlInterval) * time.Minute
+	for _, ch := range channels {
+		if staleAfter == 0 || !c.index.Fresh(ch, staleAfter) {
+			stale = append(stale, ch)
 		}
 	}
 
-	// 如果没有代理，尝试直接TCP连接
-	if c.config.Proxy == "" {
-		conn, err := net.DialTimeout("tcp", "api.telegram.org:443", 
-			time.Duration(c.config.CheckTimeout)*time.Second)
-		if err == nil {
-			conn.Close()
-			c.available = true
-			log.Println("[TG] Telegram网络连接正常（TCP）")
-			return
+	if len(stale) > 0 && c.IsAvailable() {
+		seen := make(map[string]bool, len(results))
+		for _, r := range results {
+			seen[r.UniqueID] = true
+		}
+		for _, r := range c.searchWeb(ctx, keyword, stale) {
+			if !seen[r.UniqueID] {
+				results = append(results, r)
+			}
 		}
 	}
 
-	log.Println("[TG] Telegram网络不可访问，将跳过TG搜索")
-	c.available = false
+	log.Printf("[TG] 搜索关键词: %s, 频道数: %d, 结果数: %d", keyword, len(channels), len(results))
+
+	return results, nil
 }
 
-// IsAvailable 返回Telegram是否可用
-func (c *Client) IsAvailable() bool {
-	return c.available
+// appendMissing 返回 list 加上 extra 中不在 list 内的频道（忽略大小写），不修改原切片
+func appendMissing(list, extra []string) []string {
+	seen := make(map[string]bool, len(list))
+	for _, ch := range list {
+		seen[strings.ToLower(ch)] = true
+	}
+	result := append([]string(nil), list...)
+	for _, ch := range extra {
+		if !seen[strings.ToLower(ch)] {
+			seen[strings.ToLower(ch)] = true
+			result = append(result, ch)
+		}
+	}
+	return result
 }
 
-// Search 搜索Telegram频道
-func (c *Client) Search(keyword string, channels []string) ([]model.SearchResult, error) {
-	if !c.available {
-		return []model.SearchResult{}, nil
+// searchIndex 搜索本地索引，按 MaxAgeDays 和每频道 MaxMessages 过滤
+func (c *Client) searchIndex(keyword string, channels []string) []model.SearchResult {
+	cutoff := c.cutoff()
+	maxMessages := c.cfg().MaxMessages
+	perChannel := make(map[string]int)
+	results := make([]model.SearchResult, 0)
+	for _, r := range c.index.Search(keyword, channels) {
+		if !cutoff.IsZero() && !r.PublishTime.IsZero() && r.PublishTime.Before(cutoff) {
+			continue
+		}
+		if perChannel[r.Channel] >= maxMessages {
+			continue
+		}
+		perChannel[r.Channel]++
+		results = append(results, r)
 	}
+	return results
+}
 
-	if len(channels) == 0 {
-		channels = c.config.Channels
+// searchWeb 使用Telegram公开Web界面并发搜索多个频道，按频道顺序汇总结果
+func (c *Client) searchWeb(ctx context.Context, keyword string, channels []string) []model.SearchResult {
+	channelResults := make([][]model.SearchResult, len(channels))
+	sem := make(chan struct{}, int(c.concurrency.Load()))
+	var wg sync.WaitGroup
+
+	for i, channel := range channels {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+
+		go func(i int, channel string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			results, err := c.searchChannel(ctx, keyword, channel)
+			if err != nil {
+				log.Printf("[TG] 搜索频道 %s 失败: %v", channel, err)
+				// 网络错误（非请求取消）时立即复查连通性
+				if ctx.Err() == nil && isNetworkError(err) {
+					c.requestCheck()
+				}
+			}
+			channelResults[i] = results
+		}(i, channel)
 	}
+	wg.Wait()
 
 	results := make([]model.SearchResult, 0)
+	for _, r := range channelResults {
+		results = append(results, r...)
+	}
+	return results
+}
+
+// searchChannel 搜索单个频道，沿 before 游标向前翻页
+// 达到 MaxPages 页、MaxMessages 条结果或消息早于 MaxAgeDays 时停止
+// 翻页中途失败时返回已获取的结果
+func (c *Client) searchChannel(ctx context.Context, keyword string, channel string) ([]model.SearchResult, error) {
+	cfg := c.cfg()
+	cutoff := c.cutoff()
 
-	// 使用Telegram公开Web API搜索
-	for _, channel := range channels {
-		channelResults, err := c.searchChannel(keyword, channel)
+	results := make([]model.SearchResult, 0)
+	before := 0
+	for pageNum := 0; pageNum < cfg.MaxPages; pageNum++ {
+		page, err := c.fetchPage(ctx, keyword, channel, before)
 		if err != nil {
-			log.Printf("[TG] 搜索频道 %s 失败: %v", channel, err)
-			continue
+			return results, err
+		}
+
+		for _, r := range page.results {
+			if !cutoff.IsZero() && !r.PublishTime.IsZero() && r.PublishTime.Before(cutoff) {
+				continue
+			}
+			results = append(results, r)
+		}
+
+		if len(results) >= cfg.MaxMessages {
+			results = results[:cfg.MaxMessages]
+			break
+		}
+		// 没有更早的消息，或已翻到时效范围之外
+		if page.messages == 0 || page.before <= 1 || (before > 0 && page.before >= before) {
+			break
 		}
-		results = append(results, channelResults...)
+		if !cutoff.IsZero() && !page.oldest.IsZero() && page.oldest.Before(cutoff) {
+			break
+		}
+		before = page.before
 	}
 
-	log.Printf("[TG] 搜索关键词: %s, 频道数: %d, 结果数: %d", keyword, len(channels), len(results))
-	
+	log.Printf("[TG] 频道 %s 搜索完成，结果数: %d", channel, len(results))
 	return results, nil
 }
 
-// searchChannel 搜索单个频道
-func (c *Client) searchChannel(keyword string, channel string) ([]model.SearchResult, error) {
-	// 注意：这是简化实现，使用公开的Telegram Web界面
-	// 更完整的实现需要Bot Token或MTProto
-	
-	// 尝试通过t.me访问频道
-	channelURL := fmt.Sprintf("https://t.me/s/%s", channel)
-	
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+// fetchPage 获取一页 t.me/s/<channel>?q=<keyword> 搜索结果
+// keyword 为空时获取频道消息列表，before 为0时获取最新一页
+func (c *Client) fetchPage(ctx context.Context, keyword, channel string, before int) (*channelPage, error) {
+	params := url.Values{}
+	if keyword != "" {
+		params.Set("q", keyword)
+	}
+	if before > 0 {
+		params.Set("before", strconv.Itoa(before))
+	}
+	channelURL := "https://t.me/s/" + channel
+	if len(params) > 0 {
+		channelURL += "?" + params.Encode()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
 	defer cancel()
-	
+
 	req, err := http.NewRequestWithContext(ctx, "GET", channelURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	
+
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("HTTP状态码: %d", resp.StatusCode)
 	}
-	
-	// 这里需要解析HTML并提取包含关键词的消息
-	// 由于Telegram Web的HTML结构复杂，这里返回空结果
-	// 完整实现建议使用Bot API或第三方服务
-	
-	log.Printf("[TG] 频道 %s 搜索完成（简化版）", channel)
-	return []model.SearchResult{}, nil
-}
 
-// SearchWithBotAPI 使用Bot API搜索（需要Bot Token）
-func (c *Client) SearchWithBotAPI(keyword string, channels []string, botToken string) ([]model.SearchResult, error) {
-	if !c.available {
-		return []model.SearchResult{}, nil
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 
-	// TODO: 实现Bot API搜索
-	// 1. 使用botToken调用getUpdates或searchMessages
-	// 2. 过滤包含keyword的消息
-	// 3. 提取网盘链接
-	
-	return []model.SearchResult{}, fmt.Errorf("Bot API搜索待实现")
+	return parseChannelPage(string(body), channel)
+}
+
+// SearchWithBotAPI 只搜索Bot API收录的本地索引（需要配置 bot_token）
+func (c *Client) SearchWithBotAPI(keyword string, channels []string) ([]model.SearchResult, error) {
+	if c.bot == nil {
+		return []model.SearchResult{}, fmt.Errorf("未配置Bot Token")
+	}
+	return c.searchIndex(keyword, channels), nil
 }
 
 // SearchWithMTProto 使用MTProto搜索（需要API ID和Hash）
 func (c *Client) SearchWithMTProto(keyword string, channels []string, apiID int, apiHash string) ([]model.SearchResult, error) {
-	if !c.available {
+	if !c.IsAvailable() {
 		return []model.SearchResult{}, nil
 	}
 
 	// TODO: 实现MTProto搜索
 	// 需要使用第三方库如 github.com/gotd/td
-	
+
 	return []model.SearchResult{}, fmt.Errorf("MTProto搜索待实现")
 }
 
 // RefreshAvailability 重新检查可用性
 func (c *Client) RefreshAvailability() {
-	c.checkAvailability()
+	c.Check(context.Background())
+}
+
+// isNetworkError 判断是否为网络层错误
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
 }
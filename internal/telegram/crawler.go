@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"pansou-openwrt/internal/model"
+)
+
+const (
+	crawlTimeout = 2 * time.Minute // 单轮爬取的超时时间
+	saveInterval = 2 * time.Minute // 索引写盘间隔
+)
+
+// crawlLoop 后台定期增量爬取配置的频道，收录到本地索引
+func (c *Client) crawlLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.crawlAll()
+
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// crawlAll 并发爬取所有配置的频道，网络不可达时跳过本轮
+func (c *Client) crawlAll() {
+	if !c.IsAvailable() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), crawlTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	start := time.Now()
+	before := c.index.Len()
+	sem := make(chan struct{}, int(c.concurrency.Load()))
+	var wg sync.WaitGroup
+
+	for _, channel := range c.cfg().Channels {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+
+		go func(channel string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := c.crawlChannel(ctx, channel); err != nil {
+				log.Printf("[TG] 爬取频道 %s 失败: %v", channel, err)
+			}
+		}(channel)
+	}
+	wg.Wait()
+
+	if added := c.index.Len() - before; added > 0 {
+		log.Printf("[TG] 频道爬取完成，新增 %d 条消息，索引共 %d 条，耗时 %v",
+			added, c.index.Len(), time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// crawlChannel 从最新一页开始向前翻页，收录上次爬取之后的新消息
+// 最多翻 MaxPages 页，遇到已收录的消息或超出 MaxAgeDays 时停止
+func (c *Client) crawlChannel(ctx context.Context, channel string) error {
+	state := c.index.State(channel)
+	cutoff := c.cutoff()
+
+	latest := state.LastID
+	results := make([]model.SearchResult, 0)
+	before := 0
+	for pageNum := 0; pageNum < c.cfg().MaxPages; pageNum++ {
+		page, err := c.fetchPage(ctx, "", channel, before)
+		if err != nil {
+			// 保留已获取的消息，但不更新爬取时间，下次仍视为过期
+			c.index.Add(results...)
+			return err
+		}
+
+		if page.latest > latest {
+			latest = page.latest
+		}
+		for _, r := range page.results {
+			if messageID(r) > state.LastID {
+				results = append(results, r)
+			}
+		}
+
+		if page.messages == 0 || page.before <= state.LastID+1 || (before > 0 && page.before >= before) {
+			break
+		}
+		if !cutoff.IsZero() && !page.oldest.IsZero() && page.oldest.Before(cutoff) {
+			break
+		}
+		before = page.before
+	}
+
+	c.index.Add(results...)
+	c.index.SetState(channel, channelState{LastID: latest, LastCrawl: time.Now()})
+	return nil
+}
+
+// persistLoop 定期将索引写入磁盘
+func (c *Client) persistLoop() {
+	ticker := time.NewTicker(saveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if err := c.index.Save(); err != nil {
+				log.Printf("[TG] %v", err)
+			}
+		}
+	}
+}
+
+// cutoff 返回 MaxAgeDays 对应的最早时间，不限制时返回零值
+func (c *Client) cutoff() time.Time {
+	days := c.cfg().MaxAgeDays
+	if days <= 0 {
+		return time.Time{}
+	}
+	return time.Now().AddDate(0, 0, -days)
+}
@@ -0,0 +1,286 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"pansou-openwrt/internal/fsutil"
+	"pansou-openwrt/internal/model"
+)
+
+const (
+	maxIndexMessages = 10000           // 本地索引最多保存的消息数，超出后淘汰最早的消息
+	indexEvictTarget = 9000            // 淘汰后保留的消息数，批量淘汰避免每次收录都重新排序
+	indexFileName    = "tg_index.json" // 索引文件名
+	indexVersion     = 1               // 索引文件格式版本
+)
+
+// index 已收录频道消息的本地索引，可持久化到磁盘
+// 消息来自后台爬虫和Bot API收录
+type index struct {
+	mu       sync.RWMutex
+	path     string                   // 索引文件路径，为空时不持久化
+	messages map[string]*indexEntry   // UniqueID -> 消息
+	channels map[string]*channelState // 频道名（小写） -> 爬取状态
+	bots     map[string]string        // Bot API收录过消息的频道：频道名（小写） -> 频道名
+	dirty    bool
+	saveMu   sync.Mutex // 串行化写文件
+}
+
+// indexEntry 索引中的一条消息
+type indexEntry struct {
+	result model.SearchResult
+	text   string // 小写的标题和正文，用于匹配关键词
+}
+
+// channelState 频道的增量爬取状态
+type channelState struct {
+	LastID    int       `json:"last_id"`    // 已收录的最大消息ID
+	LastCrawl time.Time `json:"last_crawl"` // 最近一次成功爬取的时间
+}
+
+// indexFile 索引文件格式
+type indexFile struct {
+	Version     int                      `json:"version"`
+	Channels    map[string]*channelState `json:"channels"`
+	BotChannels []string                 `json:"bot_channels,omitempty"`
+	Messages    []model.SearchResult     `json:"messages"`
+}
+
+// newIndex 创建本地索引，dir 为空时只保存在内存中
+func newIndex(dir string) *index {
+	idx := &index{
+		messages: make(map[string]*indexEntry),
+		channels: make(map[string]*channelState),
+		bots:     make(map[string]string),
+	}
+	if dir != "" {
+		idx.path = filepath.Join(dir, indexFileName)
+	}
+	return idx
+}
+
+// Add 收录消息，相同 UniqueID 的消息（如编辑后的消息）会被覆盖
+func (idx *index) Add(results ...model.SearchResult) {
+	if len(results) == 0 {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, r := range results {
+		idx.messages[r.UniqueID] = &indexEntry{
+			result: r,
+			text:   strings.ToLower(r.Title + "\n" + r.Description),
+		}
+	}
+	if len(idx.messages) > maxIndexMessages {
+		idx.evict(len(idx.messages) - indexEvictTarget)
+	}
+	idx.dirty = true
+}
+
+// AddBot 收录Bot API接收的频道消息，并记录消息所在的频道
+func (idx *index) AddBot(r model.SearchResult) {
+	idx.Add(r)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if key := strings.ToLower(r.Channel); idx.bots[key] == "" {
+		idx.bots[key] = r.Channel
+		idx.dirty = true
+	}
+}
+
+// BotChannels 返回Bot API收录过消息的频道（没有公开用户名的频道为数字ID）
+func (idx *index) BotChannels() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	channels := make([]string, 0, len(idx.bots))
+	for _, ch := range idx.bots {
+		channels = append(channels, ch)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// evict 淘汰最早的 n 条消息，调用方需持有写锁
+func (idx *index) evict(n int) {
+	all := make([]*indexEntry, 0, len(idx.messages))
+	for _, e := range idx.messages {
+		all = append(all, e)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].result.PublishTime.Before(all[j].result.PublishTime)
+	})
+	for _, e := range all[:n] {
+		delete(idx.messages, e.result.UniqueID)
+	}
+}
+
+// Len 返回已收录的消息数
+func (idx *index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.messages)
+}
+
+// Search 返回标题或正文包含关键词所有分词的消息，按时间从新到旧排列
+// channels 为空时搜索所有频道
+func (idx *index) Search(keyword string, channels []string) []model.SearchResult {
+	terms := strings.Fields(strings.ToLower(keyword))
+	if len(terms) == 0 {
+		return []model.SearchResult{}
+	}
+
+	allowed := make(map[string]bool, len(channels))
+	for _, ch := range channels {
+		allowed[strings.ToLower(ch)] = true
+	}
+
+	idx.mu.RLock()
+	results := make([]model.SearchResult, 0)
+	for _, e := range idx.messages {
+		if len(allowed) > 0 && !allowed[strings.ToLower(e.result.Channel)] {
+			continue
+		}
+		if matchTerms(e.text, terms) {
+			results = append(results, e.result)
+		}
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].PublishTime.After(results[j].PublishTime)
+	})
+	return results
+}
+
+// matchTerms 判断文本是否包含所有分词
+func matchTerms(text string, terms []string) bool {
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// State 返回频道的爬取状态，从未爬取时返回零值
+func (idx *index) State(channel string) channelState {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if st, ok := idx.channels[strings.ToLower(channel)]; ok {
+		return *st
+	}
+	return channelState{}
+}
+
+// SetState 更新频道的爬取状态
+func (idx *index) SetState(channel string, st channelState) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.channels[strings.ToLower(channel)] = &st
+	idx.dirty = true
+}
+
+// Fresh 判断频道的索引是否在 maxAge 内更新过
+func (idx *index) Fresh(channel string, maxAge time.Duration) bool {
+	st := idx.State(channel)
+	return !st.LastCrawl.IsZero() && time.Since(st.LastCrawl) < maxAge
+}
+
+// Load 从磁盘加载索引，文件不存在时忽略
+func (idx *index) Load() error {
+	if idx.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(idx.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取索引文件失败: %w", err)
+	}
+
+	var file indexFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析索引文件失败: %w", err)
+	}
+	if file.Version != indexVersion {
+		return fmt.Errorf("索引文件版本不兼容: %d", file.Version)
+	}
+
+	idx.Add(file.Messages...)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for name, st := range file.Channels {
+		idx.channels[name] = st
+	}
+	for _, ch := range file.BotChannels {
+		idx.bots[strings.ToLower(ch)] = ch
+	}
+	idx.dirty = false
+	return nil
+}
+
+// Save 将索引写入磁盘（先写临时文件再重命名，避免写入中断损坏索引），没有变化时跳过
+func (idx *index) Save() error {
+	if idx.path == "" {
+		return nil
+	}
+
+	idx.saveMu.Lock()
+	defer idx.saveMu.Unlock()
+
+	idx.mu.Lock()
+	if !idx.dirty {
+		idx.mu.Unlock()
+		return nil
+	}
+	file := indexFile{
+		Version:  indexVersion,
+		Channels: make(map[string]*channelState, len(idx.channels)),
+		Messages: make([]model.SearchResult, 0, len(idx.messages)),
+	}
+	for name, st := range idx.channels {
+		s := *st
+		file.Channels[name] = &s
+	}
+	for _, ch := range idx.bots {
+		file.BotChannels = append(file.BotChannels, ch)
+	}
+	for _, e := range idx.messages {
+		file.Messages = append(file.Messages, e.result)
+	}
+	idx.dirty = false
+	idx.mu.Unlock()
+
+	data, err := json.Marshal(file)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(idx.path), 0755)
+	}
+	if err == nil {
+		err = fsutil.WriteFileAtomic(idx.path, data, 0644)
+	}
+	if err != nil {
+		// 保存失败时保留脏标记，下次重试
+		idx.mu.Lock()
+		idx.dirty = true
+		idx.mu.Unlock()
+		return fmt.Errorf("保存索引文件失败: %w", err)
+	}
+	return nil
+}
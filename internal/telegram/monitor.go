@@ -0,0 +1,186 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"pansou-openwrt/internal/model"
+)
+
+const (
+	checkIntervalUp   = 5 * time.Minute  // 可用时的复查间隔
+	checkIntervalDown = 30 * time.Second // 不可用时的首次重试间隔，之后逐次加倍
+)
+
+// monitorLoop 后台定期检查Telegram连通性
+// 启动后立即检查一次；可用时每 checkIntervalUp 复查一次；不可用时从 checkIntervalDown 开始退避重试；
+// 搜索出现网络错误时立即复查
+func (c *Client) monitorLoop() {
+	c.Check(context.Background())
+	timer := time.NewTimer(c.nextInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-timer.C:
+		case <-c.recheck:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+
+		c.Check(context.Background())
+		timer.Reset(c.nextInterval())
+	}
+}
+
+// nextInterval 根据当前状态计算下次检查的间隔
+func (c *Client) nextInterval() time.Duration {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	if c.available.Load() {
+		c.retryDelay = 0
+		return checkIntervalUp
+	}
+	if c.retryDelay == 0 {
+		c.retryDelay = checkIntervalDown
+	} else {
+		c.retryDelay *= 2
+		if c.retryDelay > checkIntervalUp {
+			c.retryDelay = checkIntervalUp
+		}
+	}
+	return c.retryDelay
+}
+
+// requestCheck 请求后台立即复查连通性（不阻塞）
+func (c *Client) requestCheck() {
+	select {
+	case c.recheck <- struct{}{}:
+	default:
+	}
+}
+
+// Check 立即检查Telegram连通性并返回检查后的状态
+func (c *Client) Check(ctx context.Context) model.TelegramHealth {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.cfg().CheckTimeout)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err := c.probe(ctx)
+	latency := time.Since(start)
+
+	c.statusMu.Lock()
+	first := c.lastCheck.IsZero()
+	c.lastCheck = time.Now()
+	c.lastLatency = latency
+	if err != nil {
+		c.lastError = err.Error()
+	} else {
+		c.lastError = ""
+	}
+	c.statusMu.Unlock()
+
+	available := err == nil
+	if prev := c.available.Swap(available); prev != available || first {
+		if available {
+			log.Printf("[TG] Telegram网络连接正常，延迟 %v", latency.Round(time.Millisecond))
+		} else {
+			log.Printf("[TG] Telegram网络不可访问，将跳过TG搜索: %v", err)
+		}
+	}
+
+	return c.Health()
+}
+
+// probe 依次尝试HTTP和TCP连接Telegram
+func (c *Client) probe(ctx context.Context) error {
+	testURLs := []string{
+		"https://api.telegram.org",
+		"https://t.me",
+	}
+
+	var lastErr error
+	for _, testURL := range testURLs {
+		req, err := http.NewRequestWithContext(ctx, "HEAD", testURL, nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 500 {
+			return nil
+		}
+		lastErr = fmt.Errorf("%s 返回状态码: %d", testURL, resp.StatusCode)
+	}
+
+	// 如果没有代理，尝试直接TCP连接
+	if !c.proxied && ctx.Err() == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", "api.telegram.org:443")
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("无法连接Telegram")
+	}
+	return lastErr
+}
+
+// Health 返回最近一次连通性检查的结果
+func (c *Client) Health() model.TelegramHealth {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+
+	h := model.TelegramHealth{
+		Available:     c.available.Load(),
+		LastLatencyMs: c.lastLatency.Milliseconds(),
+		LastError:     c.lastError,
+	}
+	if !c.lastCheck.IsZero() {
+		t := c.lastCheck
+		h.LastCheck = &t
+	}
+	h.IndexedMessages = c.index.Len()
+	if c.bot != nil {
+		lastPoll, lastErr := c.bot.status()
+		h.Bot = &model.TelegramBotHealth{
+			LastError: lastErr,
+		}
+		if !lastPoll.IsZero() {
+			h.Bot.LastPoll = &lastPoll
+		}
+	}
+	return h
+}
+
+// Close 停止后台任务并保存本地索引
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		if c.bot != nil {
+			c.bot.wait()
+		}
+		if err := c.index.Save(); err != nil {
+			log.Printf("[TG] %v", err)
+		}
+	})
+}
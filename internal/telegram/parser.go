@@ -0,0 +1,158 @@
+package telegram
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"pansou-openwrt/internal/links"
+	"pansou-openwrt/internal/model"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+var (
+	// 标题行常见前缀
+	titlePrefixRegex = regexp.MustCompile(`^(?:名称|资源名称|标题|片名)\s*[：:]\s*`)
+
+	// <br> 换行
+	brRegex = regexp.MustCompile(`(?i)<br\s*/?>`)
+)
+
+// channelPage 一页频道消息的解析结果
+type channelPage struct {
+	results  []model.SearchResult // 包含网盘链接的消息，按时间从新到旧排列
+	messages int                  // 页面中的消息总数（含无链接的消息）
+	before   int                  // 下一页的 before 游标（本页最小的消息ID）
+	latest   int                  // 本页最大的消息ID
+	oldest   time.Time            // 本页最早一条消息的时间
+}
+
+// parseChannelPage 解析 t.me/s/<channel> 页面，返回包含网盘链接的消息和翻页游标
+func parseChannelPage(htmlData, channel string) (*channelPage, error) {
+	// goquery 的 Text() 会丢弃 <br>，先替换为换行以便按行提取标题
+	htmlData = brRegex.ReplaceAllString(htmlData, "\n")
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlData))
+	if err != nil {
+		return nil, fmt.Errorf("HTML解析失败: %w", err)
+	}
+
+	page := &channelPage{results: make([]model.SearchResult, 0)}
+	doc.Find(".tgme_widget_message").Each(func(i int, s *goquery.Selection) {
+		page.messages++
+
+		post, _ := s.Attr("data-post") // channel/123
+		if id, err := strconv.Atoi(post[strings.LastIndex(post, "/")+1:]); err == nil {
+			if page.before == 0 || id < page.before {
+				page.before = id
+			}
+			if id > page.latest {
+				page.latest = id
+			}
+		}
+
+		if t := messageTime(s); !t.IsZero() && (page.oldest.IsZero() || t.Before(page.oldest)) {
+			page.oldest = t
+		}
+
+		if result, ok := parseMessage(s, channel); ok {
+			page.results = append(page.results, result)
+		}
+	})
+
+	// 页面中的消息按时间从旧到新排列，反转为从新到旧
+	for i, j := 0, len(page.results)-1; i < j; i, j = i+1, j-1 {
+		page.results[i], page.results[j] = page.results[j], page.results[i]
+	}
+
+	return page, nil
+}
+
+// messageTime 解析消息的发布时间
+func messageTime(s *goquery.Selection) time.Time {
+	if datetime, ok := s.Find(".tgme_widget_message_date time").Attr("datetime"); ok {
+		if t, err := time.Parse(time.RFC3339, datetime); err == nil {
+			return t.Local()
+		}
+	}
+	return time.Time{}
+}
+
+// parseMessage 解析单条消息
+func parseMessage(s *goquery.Selection, channel string) (model.SearchResult, bool) {
+	post, _ := s.Attr("data-post") // channel/123
+	msgID := post[strings.LastIndex(post, "/")+1:]
+	if msgID == "" {
+		return model.SearchResult{}, false
+	}
+
+	textEl := s.Find(".tgme_widget_message_text").First()
+	text := strings.TrimSpace(textEl.Text())
+
+	// 候选链接：正文中的超链接和链接预览（纯文本URL由 buildResult 提取）
+	var candidates []string
+	textEl.Find("a[href]").Each(func(j int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		candidates = append(candidates, href)
+	})
+	s.Find("a.tgme_widget_message_link_preview").Each(func(j int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		candidates = append(candidates, href)
+	})
+
+	return buildResult(channel, msgID, text, candidates, messageTime(s))
+}
+
+// buildResult 由消息文本构建搜索结果，消息中没有网盘链接时返回false
+// candidates 为消息中超链接的地址，正文中的纯文本链接会自动提取
+func buildResult(channel, msgID, text string, candidates []string, publishTime time.Time) (model.SearchResult, bool) {
+	shareLinks := links.Extract(text, candidates)
+	if len(shareLinks) == 0 {
+		return model.SearchResult{}, false
+	}
+
+	title, description := splitText(text)
+	if title == "" {
+		title = shareLinks[0].URL
+	}
+
+	result := model.SearchResult{
+		UniqueID:    channel + ":" + msgID,
+		Title:       title,
+		Description: description,
+		Links:       shareLinks,
+		Source:      "tg:" + channel,
+		Channel:     channel,
+		PublishTime: publishTime,
+	}
+	if !publishTime.IsZero() {
+		result.Datetime = publishTime.Format(timeLayout)
+	}
+	return result, true
+}
+
+// messageID 从 UniqueID（channel:msgid）中解析消息ID
+func messageID(r model.SearchResult) int {
+	id, _ := strconv.Atoi(r.UniqueID[strings.LastIndex(r.UniqueID, ":")+1:])
+	return id
+}
+
+// splitText 将消息文本拆分为标题（第一行非空文本）和描述（其余文本）
+func splitText(text string) (string, string) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		// 跳过空行和只有链接的行
+		if line == "" || links.IsURL(line) {
+			continue
+		}
+		title := titlePrefixRegex.ReplaceAllString(line, "")
+		description := strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+		return title, description
+	}
+	return "", ""
+}
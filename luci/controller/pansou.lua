@@ -30,6 +30,10 @@ function index()
 		call("action_restart")).leaf = true
 	entry({"admin", "services", "pansou", "search_api"}, 
 		call("action_search")).leaf = true
+	entry({"admin", "services", "pansou", "health"}, 
+		call("action_health")).leaf = true
+	entry({"admin", "services", "pansou", "tg_check"}, 
+		call("action_tg_check")).leaf = true
 end
 
 -- 获取服务状态
@@ -89,9 +93,14 @@ function action_search()
 	local json = require "luci.jsonc"
 	local uci = require "luci.model.uci".cursor()
 	
-	-- 获取请求参数
+	-- 获取请求参数，带有翻页游标时只转发游标
 	local keyword = http.formvalue("keyword")
-	if not keyword or keyword == "" then
+	local cursor = http.formvalue("cursor")
+	local page_size = tonumber(http.formvalue("page_size")) or 0
+	if cursor and not cursor:match("^[%w_-]+$") then
+		cursor = nil
+	end
+	if not cursor and (not keyword or keyword == "") then
 		http.prepare_content("application/json")
 		http.write_json({
 			success = false,
@@ -105,9 +114,16 @@ function action_search()
 	
 	-- 构建API请求
 	local api_url = string.format("http://127.0.0.1:%s/api/search", port)
+	-- 关键词可能包含引号等高级搜索语法，按JSON编码并转义后再传给shell
+	local body
+	if cursor then
+		body = json.stringify({ cursor = cursor })
+	else
+		body = json.stringify({ keyword = keyword, result_type = "merge", page_size = page_size })
+	end
 	local curl_cmd = string.format(
-		"curl -s -X POST '%s' -H 'Content-Type: application/json' -d '{\"keyword\":\"%s\",\"result_type\":\"merge\"}'",
-		api_url, keyword
+		"curl -s -X POST '%s' -H 'Content-Type: application/json' -d %s",
+		api_url, luci.util.shellquote(body)
 	)
 	
 	-- 执行请求
@@ -117,3 +133,37 @@ function action_search()
 	http.prepare_content("application/json")
 	http.write(result)
 end
+
+-- 健康状态（插件熔断状态等）
+function action_health()
+	local http = require "luci.http"
+	local uci = require "luci.model.uci".cursor()
+	
+	local port = uci:get("pansou", "config", "port") or "8888"
+	local api_url = string.format("http://127.0.0.1:%s/api/health", port)
+	local result = luci.util.exec(string.format("curl -s -m 5 '%s'", api_url))
+	
+	http.prepare_content("application/json")
+	if result == nil or result == "" then
+		http.write_json({ status = "unavailable" })
+	else
+		http.write(result)
+	end
+end
+
+-- 立即检查Telegram连通性
+function action_tg_check()
+	local http = require "luci.http"
+	local uci = require "luci.model.uci".cursor()
+	
+	local port = uci:get("pansou", "config", "port") or "8888"
+	local api_url = string.format("http://127.0.0.1:%s/api/telegram/check", port)
+	local result = luci.util.exec(string.format("curl -s -m 30 -X POST '%s'", api_url))
+	
+	http.prepare_content("application/json")
+	if result == nil or result == "" then
+		http.write_json({ available = false, last_error = "服务未运行" })
+	else
+		http.write(result)
+	end
+end
@@ -42,6 +42,42 @@ o = s:option(Value, "cache_ttl", translate("缓存时间"),
 o.datatype = "uinteger"
 o.placeholder = "60"
 
+o = s:option(Value, "cache_stale", translate("过期缓存保留时间"),
+	translate("缓存过期后仍可先返回旧结果并在后台刷新的时间（分钟），0为不返回过期结果"))
+o.datatype = "uinteger"
+o.placeholder = "1440"
+
+o = s:option(Value, "cache_max_entries", translate("内存缓存条数"),
+	translate("内存中最多缓存的搜索结果数，超出时淘汰最久未使用的"))
+o.datatype = "uinteger"
+o.placeholder = "500"
+
+o = s:option(Value, "cache_max_memory", translate("内存缓存上限"),
+	translate("内存缓存占用上限（MB）"))
+o.datatype = "uinteger"
+o.placeholder = "16"
+
+o = s:option(Value, "cache_dir", translate("磁盘缓存目录"),
+	translate("可选，设置到U盘等外部存储后重启仍保留缓存，留空不启用"))
+o.placeholder = "/mnt/sda1/pansou-cache"
+
+o = s:option(Value, "cache_dir_max_size", translate("磁盘缓存上限"),
+	translate("磁盘缓存占用上限（MB）"))
+o.datatype = "uinteger"
+o.placeholder = "64"
+
+o = s:option(ListValue, "validate_links", translate("链接有效性检测"),
+	translate("通过网盘接口检测百度、夸克、阿里云盘、115链接是否失效，会增加搜索耗时"))
+o:value("off", translate("不检测"))
+o:value("flag", translate("标记链接状态"))
+o:value("drop", translate("移除失效链接"))
+o.default = "off"
+
+o = s:option(Value, "link_check_ttl", translate("检测结果缓存时间"),
+	translate("有效链接检测结果的缓存时间（分钟），失效链接固定缓存24小时"))
+o.datatype = "uinteger"
+o.placeholder = "360"
+
 -- Telegram配置
 s = m:section(TypedSection, "telegram", translate("Telegram设置"))
 s.anonymous = true
@@ -60,10 +96,55 @@ o = s:option(Value, "check_timeout", translate("网络检测超时"),
 o.datatype = "uinteger"
 o.placeholder = "5"
 
-o = s:option(Value, "proxy", translate("代理设置"),
-	translate("SOCKS5代理，格式：socks5://127.0.0.1:1080"))
+o = s:option(Value, "proxy", translate("TG专用代理"),
+	translate("设置后Telegram请求全部走此代理；留空时先直连，失败后走全局代理。同时设置全局代理时两者必须相同"))
 o.placeholder = "socks5://127.0.0.1:1080"
 
+o = s:option(Value, "max_pages", translate("最大翻页数"),
+	translate("每个频道最多向前翻多少页历史消息（每页约20条）"))
+o.datatype = "range(1,20)"
+o.placeholder = "3"
+
+o = s:option(Value, "max_messages", translate("每频道结果数"),
+	translate("每个频道最多返回的结果数"))
+o.datatype = "uinteger"
+o.placeholder = "50"
+
+o = s:option(Value, "max_age_days", translate("消息时效"),
+	translate("只搜索最近多少天的消息，0为不限制"))
+o.datatype = "uinteger"
+o.placeholder = "0"
+
+o = s:option(Value, "bot_token", translate("Bot Token"),
+	translate("可选。将机器人设为频道管理员后通过Bot API收录频道新消息，t.me网页不可访问时仍可搜索"))
+o.password = true
+o.placeholder = "123456:ABC-DEF..."
+
+o = s:option(Value, "crawl_interval", translate("后台爬取间隔"),
+	translate("定期收录频道新消息到本地索引（分钟），0为关闭；开启后搜索优先使用本地索引"))
+o.datatype = "uinteger"
+o.placeholder = "10"
+
+o = s:option(Value, "index_dir", translate("索引目录"),
+	translate("本地索引保存目录，/tmp重启后丢失，可设置为U盘路径"))
+o.placeholder = "/tmp/pansou"
+
+-- 出站代理
+s = m:section(TypedSection, "proxy", translate("代理设置"))
+s.anonymous = true
+s.addremove = false
+s.description = translate("插件和Telegram共用的出站代理，可按插件选择是否使用")
+
+o = s:option(Value, "url", translate("代理地址"),
+	translate("支持 socks5://用户名:密码@127.0.0.1:1080 和 http://127.0.0.1:8080，留空不使用代理"))
+o.placeholder = "socks5://127.0.0.1:1080"
+
+o = s:option(ListValue, "mode", translate("插件默认模式"))
+o:value("direct", translate("直连"))
+o:value("proxy", translate("走代理"))
+o:value("auto", translate("自动（直连失败后走代理）"))
+o.default = "direct"
+
 -- 插件配置
 s = m:section(TypedSection, "plugins", translate("插件设置"))
 s.anonymous = true
@@ -73,41 +154,42 @@ o = s:option(Flag, "enabled", translate("启用插件"),
 	translate("全局启用/禁用所有搜索插件"))
 o.rmempty = false
 
--- 插件列表
-local plugins = {
-	{"xys", "小云搜索", 2},
-	{"miaoso", "喵搜", 3},
-	{"jutoushe", "剧透社", 1},
-	{"alupan", "阿鲁盘", 2},
-	{"mikuclub", "米酷", 2},
-	{"ypfxw", "云盘分享网", 3},
-	{"kkmao", "KK猫", 3},
-	{"clxiong", "磁力熊", 2},
-	{"ash", "ASH", 2},
-	{"qingying", "轻影", 3},
-	{"meitizy", "美剧资源", 3},
-	{"wuji", "无极", 3},
-	{"dyyj", "电影院", 3},
-	{"labi", "拉比", 3},
-	{"zxzj", "在线之家", 3},
-	{"ddys", "低端影视", 3},
-	{"lou1", "Lou1", 2},
-	{"panyq", "盘友圈", 1},
-}
+-- 插件列表（由程序内置的插件注册表提供）
+local plugins = {}
+for line in luci.sys.exec("/usr/bin/pansou-openwrt -list-plugins 2>/dev/null"):gmatch("[^\n]+") do
+	local name, priority, enabled, display = line:match("^(%S+)\t(%d+)\t(%d)\t(.*)$")
+	if name then
+		plugins[#plugins + 1] = {name, display, tonumber(priority), enabled}
+	end
+end
 
 for _, plugin in ipairs(plugins) do
-	local name, display, priority = plugin[1], plugin[2], plugin[3]
+	local name, display, priority, enabled = plugin[1], plugin[2], plugin[3], plugin[4]
 	
 	o = s:option(Flag, "plugin_" .. name, display,
-		string.format("优先级: %d", priority))
+		string.format("默认优先级: %d", priority))
+	o.default = enabled
 	o.rmempty = false
+	
+	o = s:option(Value, "priority_" .. name, display .. " " .. translate("优先级"),
+		translate("1为最高，留空使用默认值；优先级高的插件优先占用并发名额，结果排在前面"))
+	o.datatype = "range(1,10)"
+	o.placeholder = priority
+	o:depends("plugin_" .. name, "1")
+	
+	o = s:option(ListValue, "proxy_" .. name, display .. " " .. translate("代理"))
+	o:value("", translate("默认"))
+	o:value("direct", translate("直连"))
+	o:value("proxy", translate("走代理"))
+	o:value("auto", translate("自动"))
+	o:depends("plugin_" .. name, "1")
 end
 
 -- 网盘类型过滤
 s = m:section(TypedSection, "cloud_types", translate("网盘类型"))
 s.anonymous = true
 s.addremove = false
-s.description = translate("选择要搜索的网盘类型")
+s.description = translate("选择要搜索的网盘类型，搜索请求中指定 cloud_types 时以请求为准；未识别的链接归入“其他链接”")
 
 local cloud_types = {
 	{"baidu", "百度网盘"},
@@ -120,8 +202,10 @@ local cloud_types = {
 	{"pikpak", "PikPak"},
 	{"xunlei", "迅雷网盘"},
 	{"123", "123盘"},
+	{"lanzou", "蓝奏云"},
 	{"magnet", "磁力链接"},
 	{"ed2k", "ed2k链接"},
+	{"others", "其他链接"},
 }
 
 for _, cloud in ipairs(cloud_types) do
@@ -9,6 +9,8 @@ import (
 	"syscall"
 
 	"pansou-openwrt/internal/config"
+	"pansou-openwrt/internal/plugin"
+	_ "pansou-openwrt/internal/plugin/plugins" // 插件在 init() 中自注册
 	"pansou-openwrt/internal/server"
 )
 
@@ -19,8 +21,11 @@ var (
 
 func main() {
 	// 命令行参数
-	configPath := flag.String("config", "/etc/pansou/config.yaml", "配置文件路径")
+	configPath := flag.String("config", "/etc/pansou/config.yaml", "配置文件路径，指定 -uci 时作为覆盖层，不存在也可以")
+	uciPath := flag.String("uci", "", "UCI配置文件路径（如 /etc/config/pansou），为空时只读取YAML配置")
 	showVersion := flag.Bool("version", false, "显示版本信息")
+	listPlugins := flag.Bool("list-plugins", false, "列出已注册的插件（供LuCI使用）")
+	watchInterval := flag.Duration("watch", 0, "检查配置文件变化的间隔（如 10s），变化时自动重新加载，0表示不检查")
 	flag.Parse()
 
 	// 显示版本信息
@@ -30,8 +35,25 @@ func main() {
 		os.Exit(0)
 	}
 
+	// 列出插件: 名称 默认优先级 默认启用 显示名称
+	if *listPlugins {
+		for _, info := range plugin.Registered() {
+			enabled := 0
+			if info.Enabled {
+				enabled = 1
+			}
+			fmt.Printf("%s\t%d\t%d\t%s\n", info.Name, info.Priority, enabled, info.DisplayName)
+		}
+		os.Exit(0)
+	}
+
 	// 加载配置
-	cfg, err := config.Load(*configPath)
+	src := &config.Source{
+		Path:          *configPath,
+		UCIPath:       *uciPath,
+		PluginEnabled: pluginEnabled,
+	}
+	cfg, err := src.Load()
 	if err != nil {
 		log.Fatalf("加载配置失败: %v", err)
 	}
@@ -40,10 +62,19 @@ func main() {
 	initLogger(cfg)
 
 	log.Printf("PanSou OpenWrt v%s 启动中...", Version)
-	log.Printf("配置文件: %s", *configPath)
+	if *uciPath != "" {
+		log.Printf("配置文件: %s（覆盖层: %s）", *uciPath, *configPath)
+	} else {
+		log.Printf("配置文件: %s", *configPath)
+	}
+
+	// 在创建和启动服务器之前注册信号，启动期间收到的SIGHUP（如procd reload）不会按默认行为终止进程，
+	// 而是在启动完成后处理
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	// 创建服务器
-	srv, err := server.New(cfg)
+	srv, err := server.New(cfg, src)
 	if err != nil {
 		log.Fatalf("创建服务器失败: %v", err)
 	}
@@ -53,16 +84,37 @@ func main() {
 		log.Fatalf("启动服务器失败: %v", err)
 	}
 
-	// 等待退出信号
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	// 配置文件变化时自动重新加载
+	if *watchInterval > 0 {
+		for _, path := range src.Files() {
+			go watchConfig(path, *watchInterval, func() {
+				reloadConfig(srv, src)
+			})
+		}
+	}
+
+	// 等待信号：SIGHUP 重新加载配置，SIGINT/SIGTERM 退出
+	for s := range sig {
+		if s != syscall.SIGHUP {
+			break
+		}
+		log.Println("收到SIGHUP，重新加载配置")
+		reloadConfig(srv, src)
+	}
 
 	log.Println("正在关闭服务器...")
 	srv.Shutdown()
 	log.Println("服务器已关闭")
 }
 
+// pluginEnabled 返回插件注册时的默认开关，未注册的插件视为启用
+func pluginEnabled(name string) bool {
+	if info, ok := plugin.Lookup(name); ok {
+		return info.Enabled
+	}
+	return true
+}
+
 func initLogger(cfg *config.Config) {
 	// 简单的日志初始化
 	// 在OpenWrt环境中，通常使用syslog或文件日志
@@ -73,6 +125,6 @@ func initLogger(cfg *config.Config) {
 			log.SetOutput(f)
 		}
 	}
-	
+
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"pansou-openwrt/internal/config"
+	"pansou-openwrt/internal/server"
+)
+
+// reloadConfig 重新读取配置并应用到运行中的服务
+// 配置文件无效时保留当前配置
+func reloadConfig(srv *server.Server, src *config.Source) {
+	cfg, err := src.Load()
+	if err != nil {
+		log.Printf("重新加载配置失败，继续使用当前配置: %v", err)
+		return
+	}
+	srv.ApplyConfig(cfg)
+}
+
+// watchConfig 定期检查配置文件的修改时间和大小，变化时调用 onChange
+// 通过轮询实现，不依赖inotify，适用于所有OpenWrt内核
+func watchConfig(path string, interval time.Duration, onChange func()) {
+	last, _ := stat(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		current, err := stat(path)
+		if err != nil || current == last {
+			continue
+		}
+		last = current
+
+		log.Printf("配置文件已修改，重新加载: %s", path)
+		onChange()
+	}
+}
+
+// fileStamp 文件的修改时间和大小，用于判断文件是否变化
+type fileStamp struct {
+	modTime int64
+	size    int64
+}
+
+func stat(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}, nil
+}